@@ -0,0 +1,29 @@
+package attrutil
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/pkg/xattr"
+)
+
+// ErrAttrExists is returned when an operation would overwrite an existing attribute.
+var ErrAttrExists = errors.New("attribute already exists")
+
+// IsNotExist reports whether err means the attribute does not exist
+// (ENOATTR, or ENODATA on Linux).
+func IsNotExist(err error) bool {
+	return errors.Is(err, xattr.ENOATTR)
+}
+
+// IsExist reports whether err means the attribute already exists, as
+// returned by SetFlag with SetCreate (EEXIST) or by MoveNS (ErrAttrExists).
+func IsExist(err error) bool {
+	return errors.Is(err, syscall.EEXIST) || errors.Is(err, ErrAttrExists)
+}
+
+// IsNotSupported reports whether err means the filesystem or platform does not
+// support extended attributes (ENOTSUP or EOPNOTSUPP).
+func IsNotSupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
@@ -0,0 +1,53 @@
+package attrutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveNS renames every attribute of path under srcNS, within a's namespace,
+// to the same key under dstNS. If a destination key already exists
+// ErrAttrExists is returned and nothing is moved, unless overwrite is set.
+// The namespaces may not overlap, as user.a and user.a.b do, since moving
+// keys one at a time could then overwrite keys not yet moved.
+func MoveNS(a Attr, path string, srcNS string, dstNS string, overwrite bool) (err error) {
+	srcNS, dstNS = strings.Trim(srcNS, "."), strings.Trim(dstNS, ".")
+	if srcNS == "" || dstNS == "" {
+		return fmt.Errorf("move %q to %q: empty namespace", srcNS, dstNS)
+	}
+	srcPrefix, dstPrefix := srcNS+".", dstNS+"."
+	if strings.HasPrefix(srcPrefix, dstPrefix) || strings.HasPrefix(dstPrefix, srcPrefix) {
+		return fmt.Errorf("move %q to %q: namespaces overlap", srcNS, dstNS)
+	}
+	var keys []string
+	if keys, err = a.List(path); err != nil {
+		return
+	}
+	existing := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		existing[key] = true
+	}
+	moves := make(map[string]string)
+	for _, key := range keys {
+		if rest, ok := strings.CutPrefix(key, srcPrefix); ok {
+			dst := dstPrefix + rest
+			if existing[dst] && !overwrite {
+				return fmt.Errorf("%s: %w", dst, ErrAttrExists)
+			}
+			moves[key] = dst
+		}
+	}
+	for src, dst := range moves {
+		var value []byte
+		if value, err = a.Get(path, src); err != nil {
+			return
+		}
+		if err = a.Set(path, dst, value); err != nil {
+			return
+		}
+		if err = a.Delete(path, src); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,27 @@
+//go:build !(linux || darwin)
+
+package attrutil
+
+import (
+	"syscall"
+
+	"github.com/pkg/xattr"
+)
+
+// setFlag emulates XATTR_CREATE and XATTR_REPLACE by reading the attribute
+// before writing it. Without flag support on this platform the check and the
+// write are not atomic.
+func setFlag(path string, name string, value []byte, mode SetMode) error {
+	if mode != SetAlways {
+		_, err := xattr.Get(path, name)
+		switch {
+		case err == nil && mode == SetCreate:
+			return &xattr.Error{Op: "xattr.SetWithFlags", Path: path, Name: name, Err: syscall.EEXIST}
+		case err != nil && !IsNotExist(err):
+			return err
+		case err != nil && mode == SetReplace:
+			return err
+		}
+	}
+	return xattr.Set(path, name, value)
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package attrutil
+
+import (
+	"github.com/pkg/xattr"
+	"golang.org/x/sys/unix"
+)
+
+// setFlag sets an attribute with the kernel's XATTR_CREATE or XATTR_REPLACE
+// semantics, so the check and the write are atomic.
+func setFlag(path string, name string, value []byte, mode SetMode) error {
+	var flags int
+	switch mode {
+	case SetCreate:
+		flags = unix.XATTR_CREATE
+	case SetReplace:
+		flags = unix.XATTR_REPLACE
+	}
+	return xattr.SetWithFlags(path, name, value, flags)
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin)
+
+package attrutil
+
+import "github.com/pkg/xattr"
+
+// attrSize returns the length of the attribute value. Without a size-only
+// call on this platform the value is read.
+func attrSize(path string, name string) (int, error) {
+	value, err := xattr.Get(path, name)
+	return len(value), err
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+
+package attrutil
+
+import (
+	"github.com/pkg/xattr"
+	"golang.org/x/sys/unix"
+)
+
+// attrSize returns the length of the attribute value without reading it,
+// using a zero-length getxattr call.
+func attrSize(path string, name string) (int, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if err != nil {
+		return 0, &xattr.Error{Op: "xattr.size", Path: path, Name: name, Err: err}
+	}
+	return size, nil
+}
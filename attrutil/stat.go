@@ -0,0 +1,46 @@
+package attrutil
+
+import (
+	"errors"
+	"os"
+)
+
+// statAttrsRetries bounds how often StatWithAttrs re-reads a file that keeps changing.
+const statAttrsRetries = 3
+
+// ErrFileChanged is returned by StatWithAttrs when the file changed on every
+// attempt to read its metadata and attributes together.
+var ErrFileChanged = errors.New("file changed while reading attributes")
+
+// StatWithAttrs returns the metadata of path together with all of its
+// attributes in a's namespace. The file is stat'ed before and after the
+// attributes are read, and the read is retried if the file changed in
+// between, so the result describes a single version of the file; if it
+// keeps changing, the error is ErrFileChanged. Like Attr, both the metadata
+// and the attributes are those of the target of a final symlink.
+func StatWithAttrs(a Attr, path string) (info os.FileInfo, attrs map[string][]byte, err error) {
+	for range statAttrsRetries {
+		if info, err = os.Stat(path); err != nil {
+			return nil, nil, err
+		}
+		if attrs, err = a.GetAttrs(path); err != nil {
+			return nil, nil, err
+		}
+		var after os.FileInfo
+		if after, err = os.Stat(path); err != nil {
+			return nil, nil, err
+		}
+		if sameVersion(info, after) {
+			return after, attrs, nil
+		}
+	}
+	return nil, nil, &os.PathError{Op: "stat", Path: path, Err: ErrFileChanged}
+}
+
+// sameVersion reports whether two stats of a path describe the same, unmodified file.
+func sameVersion(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) &&
+		a.Size() == b.Size() &&
+		a.Mode() == b.Mode() &&
+		a.ModTime().Equal(b.ModTime())
+}
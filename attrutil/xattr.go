@@ -2,10 +2,24 @@ package attrutil
 
 import (
 	"strings"
+	"syscall"
 
 	"github.com/pkg/xattr"
 )
 
+// SetMode selects whether SetFlag may create or replace an attribute.
+type SetMode int
+
+const (
+	// SetAlways creates the attribute or replaces its value, like Set.
+	SetAlways SetMode = iota
+	// SetCreate fails with an error matching IsExist if the attribute exists.
+	SetCreate
+	// SetReplace fails with an error matching IsNotExist if the attribute
+	// does not exist.
+	SetReplace
+)
+
 // osXattr implements the Attr interface using OS-specific extended attributes.
 type osXattr struct {
 	ns string
@@ -56,7 +70,36 @@ func (a *osXattr) Set(path string, name string, value []byte) (err error) {
 	return xattr.Set(path, a.name(name), value)
 }
 
+// SetFlag sets the value of the attribute name of path like a.Set, but only
+// if it does (SetReplace) or does not (SetCreate) exist yet. For Xattr the
+// check and the write are atomic where the platform supports it; other
+// implementations are read with Get first.
+func SetFlag(a Attr, path string, name string, value []byte, mode SetMode) (err error) {
+	if x, ok := a.(*osXattr); ok {
+		return setFlag(path, x.name(name), value, mode)
+	}
+	if mode != SetAlways {
+		_, err = a.Get(path, name)
+		switch {
+		case err == nil && mode == SetCreate:
+			return &xattr.Error{Op: "attrutil.SetFlag", Path: path, Name: name, Err: syscall.EEXIST}
+		case err != nil && (mode == SetReplace || !IsNotExist(err)):
+			return err
+		}
+	}
+	return a.Set(path, name, value)
+}
+
+// readAttr and readAttrSize read one attribute value or its length. They
+// are variables so tests can make a key vanish between List and the read.
+var (
+	readAttr     = xattr.Get
+	readAttrSize = attrSize
+)
+
 // GetAttrs retrieves all extended attributes for the given path.
+// Attributes removed by another process between listing and reading them are
+// left out rather than failing the call.
 func (a *osXattr) GetAttrs(path string) (attrs map[string][]byte, err error) {
 	var keys []string
 	if keys, err = a.List(path); err != nil {
@@ -64,9 +107,52 @@ func (a *osXattr) GetAttrs(path string) (attrs map[string][]byte, err error) {
 	}
 	attrs = make(map[string][]byte, len(keys))
 	for _, key := range keys {
-		if attrs[key], err = xattr.Get(path, a.name(key)); err != nil {
+		var value []byte
+		if value, err = readAttr(path, a.name(key)); IsNotExist(err) {
+			err = nil
+			continue
+		} else if err != nil {
+			return
+		}
+		attrs[key] = value
+	}
+	return
+}
+
+// ListSizes returns the value length of every attribute of path in a's
+// namespace. Like GetAttrs, vanished attributes are left out. For Xattr the
+// values are not fetched; other implementations are read with GetAttrs.
+func ListSizes(a Attr, path string) (sizes map[string]int, err error) {
+	if x, ok := a.(*osXattr); ok {
+		return x.listSizes(path)
+	}
+	var attrs map[string][]byte
+	if attrs, err = a.GetAttrs(path); err != nil {
+		return
+	}
+	sizes = make(map[string]int, len(attrs))
+	for key, value := range attrs {
+		sizes[key] = len(value)
+	}
+	return
+}
+
+// listSizes implements ListSizes with size-only reads.
+func (a *osXattr) listSizes(path string) (sizes map[string]int, err error) {
+	var keys []string
+	if keys, err = a.List(path); err != nil {
+		return
+	}
+	sizes = make(map[string]int, len(keys))
+	for _, key := range keys {
+		var size int
+		if size, err = readAttrSize(path, a.name(key)); IsNotExist(err) {
+			err = nil
+			continue
+		} else if err != nil {
 			return
 		}
+		sizes[key] = size
 	}
 	return
 }
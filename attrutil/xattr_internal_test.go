@@ -0,0 +1,89 @@
+package attrutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/xattr"
+)
+
+// newVanishFile returns an attribute namespace holding keys a, b and c on a
+// fresh file, or skips the test.
+func newVanishFile(t *testing.T) (*osXattr, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	attrs := &osXattr{ns: "user.attrutil-test"}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := attrs.Set(path, key, []byte(key+key)); IsNotSupported(err) {
+			t.Skipf("extended attributes not supported: %v", err)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return attrs, path
+}
+
+// failOn returns the error for reads of name b with errno, and nil for
+// other names.
+func failOn(attrs *osXattr, path string, name string, errno error) error {
+	if name != attrs.name("b") {
+		return nil
+	}
+	return &xattr.Error{Op: "xattr.get", Path: path, Name: name, Err: errno}
+}
+
+func TestGetAttrsSkipsVanished(t *testing.T) {
+	attrs, path := newVanishFile(t)
+	defer func(orig func(string, string) ([]byte, error)) { readAttr = orig }(readAttr)
+	var errno error = xattr.ENOATTR
+	readAttr = func(path string, name string) ([]byte, error) {
+		if err := failOn(attrs, path, name, errno); err != nil {
+			return nil, err
+		}
+		return xattr.Get(path, name)
+	}
+
+	got, err := attrs.GetAttrs(path)
+	if err != nil {
+		t.Fatalf("GetAttrs with a vanished key: %v", err)
+	}
+	if len(got) != 2 || string(got["a"]) != "aa" || string(got["c"]) != "cc" {
+		t.Fatalf("expected only a and c, got %q", got)
+	}
+
+	errno = syscall.EIO
+	if _, err = attrs.GetAttrs(path); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("GetAttrs with a failing key: got %v, want EIO", err)
+	}
+}
+
+func TestListSizesSkipsVanished(t *testing.T) {
+	attrs, path := newVanishFile(t)
+	defer func(orig func(string, string) (int, error)) { readAttrSize = orig }(readAttrSize)
+	var errno error = xattr.ENOATTR
+	readAttrSize = func(path string, name string) (int, error) {
+		if err := failOn(attrs, path, name, errno); err != nil {
+			return 0, err
+		}
+		return attrSize(path, name)
+	}
+
+	got, err := ListSizes(attrs, path)
+	if err != nil {
+		t.Fatalf("ListSizes with a vanished key: %v", err)
+	}
+	if len(got) != 2 || got["a"] != 2 || got["c"] != 2 {
+		t.Fatalf("expected only a and c, got %v", got)
+	}
+
+	errno = syscall.EIO
+	if _, err = ListSizes(attrs, path); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("ListSizes with a failing key: got %v, want EIO", err)
+	}
+}
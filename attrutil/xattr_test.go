@@ -0,0 +1,183 @@
+package attrutil_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ophymx/utils/attrutil"
+)
+
+// newFile returns a file on a filesystem with user extended attributes, or
+// skips the test.
+func newFile(t *testing.T) (attrutil.Attr, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	attrs := attrutil.Xattr().NS("user.attrutil-test")
+	if err := attrs.Set(path, "probe", nil); attrutil.IsNotSupported(err) {
+		t.Skipf("extended attributes not supported: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return attrs, path
+}
+
+// otherAttr hides the Xattr implementation, to exercise the fallbacks used
+// for other Attr implementations.
+type otherAttr struct{ attrutil.Attr }
+
+func TestSetFlagCreate(t *testing.T) {
+	attrs, path := newFile(t)
+	testSetFlagCreate(t, attrs, path)
+	attrs, path = newFile(t)
+	testSetFlagCreate(t, otherAttr{attrs}, path)
+}
+
+func testSetFlagCreate(t *testing.T, attrs attrutil.Attr, path string) {
+	t.Helper()
+	if err := attrutil.SetFlag(attrs, path, "key", []byte("first"), attrutil.SetCreate); err != nil {
+		t.Fatalf("SetCreate on a new attribute: %v", err)
+	}
+	err := attrutil.SetFlag(attrs, path, "key", []byte("second"), attrutil.SetCreate)
+	if !attrutil.IsExist(err) {
+		t.Fatalf("SetCreate on an existing attribute: got %v, want an IsExist error", err)
+	}
+	if value, err := attrs.Get(path, "key"); err != nil || string(value) != "first" {
+		t.Fatalf("expected the first value to be kept, got %q, %v", value, err)
+	}
+}
+
+func TestSetFlagReplace(t *testing.T) {
+	attrs, path := newFile(t)
+	testSetFlagReplace(t, attrs, path)
+	attrs, path = newFile(t)
+	testSetFlagReplace(t, otherAttr{attrs}, path)
+}
+
+func testSetFlagReplace(t *testing.T, attrs attrutil.Attr, path string) {
+	t.Helper()
+	err := attrutil.SetFlag(attrs, path, "key", []byte("first"), attrutil.SetReplace)
+	if !attrutil.IsNotExist(err) {
+		t.Fatalf("SetReplace on a missing attribute: got %v, want an IsNotExist error", err)
+	}
+	if err := attrs.Set(path, "key", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := attrutil.SetFlag(attrs, path, "key", []byte("second"), attrutil.SetReplace); err != nil {
+		t.Fatalf("SetReplace on an existing attribute: %v", err)
+	}
+	if value, err := attrs.Get(path, "key"); err != nil || string(value) != "second" {
+		t.Fatalf("expected the value to be replaced, got %q, %v", value, err)
+	}
+}
+
+func TestMoveNS(t *testing.T) {
+	attrs, path := newFile(t)
+	for key, value := range map[string]string{"old.a": "1", "old.b": "2", "other": "3"} {
+		if err := attrs.Set(path, key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := attrutil.MoveNS(attrs, path, "old", "new", false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := attrs.GetAttrs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"new.a": "1", "new.b": "2", "other": "3", "probe": ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for key, value := range want {
+		if string(got[key]) != value {
+			t.Errorf("%s: got %q, want %q", key, got[key], value)
+		}
+	}
+
+	if err := attrs.Set(path, "old.a", []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+	if err := attrutil.MoveNS(attrs, path, "old", "new", false); !errors.Is(err, attrutil.ErrAttrExists) || !attrutil.IsExist(err) {
+		t.Fatalf("moving onto an existing key: got %v, want ErrAttrExists matching IsExist", err)
+	}
+	if err := attrutil.MoveNS(attrs, path, "old", "new", true); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := attrs.Get(path, "new.a"); err != nil || string(value) != "4" {
+		t.Fatalf("expected new.a to be overwritten, got %q, %v", value, err)
+	}
+}
+
+func TestMoveNSOverlap(t *testing.T) {
+	attrs, path := newFile(t)
+	if err := attrs.Set(path, "a.x", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range [][2]string{{"a", "a.b"}, {"a.b", "a"}, {"a", "a"}, {"", "b"}} {
+		if err := attrutil.MoveNS(attrs, path, tc[0], tc[1], true); err == nil {
+			t.Errorf("MoveNS(%q, %q): expected an error", tc[0], tc[1])
+		}
+	}
+	if value, err := attrs.Get(path, "a.x"); err != nil || string(value) != "1" {
+		t.Fatalf("expected a.x to be left alone, got %q, %v", value, err)
+	}
+}
+
+func TestListSizes(t *testing.T) {
+	attrs, path := newFile(t)
+	if err := attrs.Set(path, "key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	sizes, err := attrutil.ListSizes(attrs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes["probe"] != 0 || sizes["key"] != 5 {
+		t.Fatalf("got %v, want probe 0 and key 5", sizes)
+	}
+}
+
+func TestStatWithAttrsFollowsSymlink(t *testing.T) {
+	attrs, path := newFile(t)
+	if err := attrs.Set(path, "key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(filepath.Dir(path), "link")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	info, values, err := attrutil.StatWithAttrs(attrs, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() || string(values["key"]) != "value" {
+		t.Errorf("got mode %v and attributes %q, want the target's", info.Mode(), values)
+	}
+}
+
+// growingAttr appends to the file on every attribute read.
+type growingAttr struct{ attrutil.Attr }
+
+func (a growingAttr) GetAttrs(path string) (map[string][]byte, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err = f.WriteString("x"); err != nil {
+		return nil, err
+	}
+	return a.Attr.GetAttrs(path)
+}
+
+func TestStatWithAttrsChanging(t *testing.T) {
+	attrs, path := newFile(t)
+	if _, _, err := attrutil.StatWithAttrs(growingAttr{attrs}, path); !errors.Is(err, attrutil.ErrFileChanged) {
+		t.Fatalf("got %v, want ErrFileChanged", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"al.essio.dev/pkg/shellescape"
+)
+
+// confirmIn is where -confirm-each reads answers from.
+var confirmIn io.Reader = os.Stdin
+
+// eachAnswer is the response to a -confirm-each prompt.
+type eachAnswer int
+
+const (
+	eachYes  eachAnswer = iota // rename this file
+	eachNo                     // skip this file
+	eachAll                    // rename this and every remaining file
+	eachQuit                   // stop without renaming any more files
+)
+
+// eachConfirmer asks before each rename with -confirm-each until the user
+// answers all, or not at all with -y.
+type eachConfirmer struct {
+	all bool
+}
+
+func newEachConfirmer() *eachConfirmer {
+	return &eachConfirmer{all: !confirmEachFlag || yesFlag}
+}
+
+// confirm asks whether to rename from to to. End of input counts as quit,
+// so a closed stdin never renames more than was confirmed.
+func (c *eachConfirmer) confirm(from, to string) eachAnswer {
+	if c.all {
+		return eachYes
+	}
+	for {
+		fmt.Printf("rename `%s' -> `%s'? [y]es, [n]o, [a]ll, [q]uit: ", shellescape.Quote(from), shellescape.Quote(to))
+		var response string
+		if _, err := fmt.Fscanln(confirmIn, &response); err == io.EOF {
+			fmt.Println()
+			return eachQuit
+		}
+		switch strings.ToLower(response) {
+		case "y", "yes":
+			return eachYes
+		case "n", "no":
+			return eachNo
+		case "a", "all":
+			c.all = true
+			return eachAll
+		case "q", "quit":
+			return eachQuit
+		}
+	}
+}
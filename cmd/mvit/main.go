@@ -17,11 +17,13 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"al.essio.dev/pkg/shellescape"
 	"github.com/ophymx/utils/txtedit/v2"
+	"golang.org/x/term"
 )
 
 // Flags for command-line options
@@ -32,6 +34,18 @@ var (
 	changeFlag      bool
 	interactiveFlag bool
 	noClobberFlag   bool
+	sortFlag        string
+	applyFlag       string
+	yesFlag         bool
+	yesThreshold    int
+	prefixFlag      string
+	suffixFlag      string
+	stripPrefixFlag string
+	trashFlag       bool
+	trashDirFlag    string
+	onlyFlag        string
+	twoColFlag      bool
+	confirmEachFlag bool
 )
 
 // Version of the mvit tool
@@ -52,6 +66,18 @@ func init() {
 	flag.BoolVar(&changeFlag, "c", false, "Only display changes")
 	flag.BoolVar(&interactiveFlag, "i", true, "Interactive mode")
 	flag.BoolVar(&noClobberFlag, "n", false, "No clobber mode")
+	flag.StringVar(&applyFlag, "apply", "", "Read renames from a file in the edit format instead of opening an editor")
+	flag.BoolVar(&yesFlag, "y", false, "Do not ask for confirmation when renaming many files")
+	flag.IntVar(&yesThreshold, "yes-threshold", 100, "Ask for confirmation when more than this many files would be renamed (0 to never ask)")
+	flag.StringVar(&prefixFlag, "prefix", "", "Add a prefix to each file name instead of opening an editor")
+	flag.StringVar(&suffixFlag, "suffix", "", "Add a suffix before the extension of each file name instead of opening an editor")
+	flag.StringVar(&stripPrefixFlag, "strip-prefix", "", "Remove a prefix from each file name instead of opening an editor")
+	flag.BoolVar(&trashFlag, "trash", false, "Move files that would be overwritten to the trash instead of replacing them")
+	flag.StringVar(&trashDirFlag, "trash-dir", "", "Directory to use with -trash instead of the XDG trash (implies -trash)")
+	flag.StringVar(&onlyFlag, "only", "", "Only list files whose base name matches a glob `pattern` in the editor; others keep their names")
+	flag.BoolVar(&twoColFlag, "two-col", false, "Edit lines as \"index: old => new\"; only the part after => is used")
+	flag.BoolVar(&confirmEachFlag, "confirm-each", false, "Ask before each rename: yes, no, all remaining or quit (-y skips the questions)")
+	flag.StringVar(&sortFlag, "sort", "", "Sort input files by name, numeric, mtime or size")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] file1 file2 ...\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "\n")
@@ -103,7 +129,7 @@ func parseRenames(maxIdx int, contents string) (map[int]string, error) {
 		renames[index] = strings.TrimSuffix(strings.TrimPrefix(parts[1], " "), "\n")
 	}
 	for index := range renames {
-		if index > maxIdx {
+		if index < 0 || index > maxIdx {
 			return nil, fmt.Errorf("%d is out of range", index)
 		}
 	}
@@ -117,8 +143,59 @@ func exists(filename string) bool {
 	return err == nil
 }
 
+// sameFile reports whether both names refer to the same file on disk.
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// resolvesToSame reports whether both names, after resolving symlinks, are the same file.
+// Renaming one onto the other would replace the file with a link to itself.
+func resolvesToSame(a, b string) bool {
+	aPath, err := filepath.EvalSymlinks(a)
+	if err != nil {
+		return false
+	}
+	bPath, err := filepath.EvalSymlinks(b)
+	if err != nil {
+		return false
+	}
+	return sameFile(aPath, bPath)
+}
+
+// isCaseOnly reports whether renaming from to to only changes letter case on a
+// case-insensitive filesystem, where both names already resolve to one file.
+func isCaseOnly(from, to string) bool {
+	return from != to && strings.EqualFold(from, to) && sameFile(from, to)
+}
+
+// renameCase renames through a temporary name so that case-insensitive
+// filesystems actually record the new case.
+func renameCase(from, to string) error {
+	tmp := from + ".mvit-tmp"
+	for i := 1; exists(tmp); i++ {
+		tmp = fmt.Sprintf("%s.mvit-tmp%d", from, i)
+	}
+	if err := os.Rename(from, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, to); err != nil {
+		os.Rename(tmp, from) // best effort restore
+		return err
+	}
+	return nil
+}
+
 // doRenames renames the files based on the provided map of index to new filenames.
 func rename(files []string, renames map[int]string) error {
+	each := newEachConfirmer()
 	for index, filename := range files {
 		if update, present := renames[index]; present {
 			if update == filename {
@@ -126,9 +203,26 @@ func rename(files []string, renames map[int]string) error {
 					fmt.Printf("`%s' unchanged\n", shellescape.Quote(filename))
 				}
 			} else {
-				if changeFlag || verboseFlag {
+				asked := !each.all
+				switch each.confirm(filename, update) {
+				case eachNo:
+					continue
+				case eachQuit:
+					return nil
+				}
+				if !asked && (changeFlag || verboseFlag) {
 					fmt.Printf("`%s' -> `%s'\n", shellescape.Quote(filename), shellescape.Quote(update))
 				}
+				if isCaseOnly(filename, update) {
+					if err := renameCase(filename, update); err != nil {
+						return fmt.Errorf("error renaming `%s' to `%s': %w", shellescape.Quote(filename), shellescape.Quote(update), err)
+					}
+					continue
+				}
+				if resolvesToSame(filename, update) {
+					fmt.Printf("`%s' and `%s' are the same file, skipping\n", shellescape.Quote(filename), shellescape.Quote(update))
+					continue
+				}
 				if exists(update) {
 					if noClobberFlag {
 						fmt.Printf("`%s' already exists, skipping\n", shellescape.Quote(update))
@@ -141,6 +235,11 @@ func rename(files []string, renames map[int]string) error {
 							continue
 						}
 					}
+					if trashFlag {
+						if err := trash(update); err != nil {
+							return fmt.Errorf("error moving `%s' to the trash: %w", shellescape.Quote(update), err)
+						}
+					}
 				}
 				if err := os.Rename(filename, update); err != nil {
 					return fmt.Errorf("error renaming `%s' to `%s': %w", shellescape.Quote(filename), shellescape.Quote(update), err)
@@ -157,28 +256,127 @@ func rename(files []string, renames map[int]string) error {
 }
 
 // mvit renames the files based on the edited contents.
+// With -apply the contents are read from that file instead of an editor, and
+// with -prefix, -suffix or -strip-prefix the new names are computed.
 func mvit(files []string) (err error) {
-	var sb strings.Builder
-	format := mvitFmt(len(files))
-	for index, filename := range files {
-		sb.WriteString(fmt.Sprintf(format, index, filename))
+	if transforming() {
+		var renames map[int]string
+		if files, renames, err = transformRenames(files); err != nil {
+			return err
+		}
+		if err = confirmRenames(countRenames(files, renames)); err != nil {
+			return err
+		}
+		return rename(files, renames)
+	}
+	if applyFlag != "" {
+		var b []byte
+		if b, err = os.ReadFile(applyFlag); err != nil {
+			return fmt.Errorf("error reading renames: %w", err)
+		}
+		return applyRenames(files, string(b))
 	}
 
 	var edited string
 	cfg := txtedit.DefaultConfig()
 	cfg.Pattern = "mvit-*.txt"
-	edited, err = txtedit.EditString(sb.String(), cfg)
+	edited, err = txtedit.EditString(editTemplate(files), cfg)
 	if err != nil {
 		return fmt.Errorf("error editing file: %w", err)
 	}
-	renames, err := parseRenames(len(files)-1, edited)
+	return applyRenames(files, edited)
+}
+
+// editTemplate returns the editor contents listing files by index. With
+// -only, files whose base name does not match are left out; indexes still
+// refer to the full list, so the files left out are not renamed.
+func editTemplate(files []string) string {
+	var sb strings.Builder
+	format := mvitFmt(len(files))
+	for index, filename := range files {
+		if onlyFlag != "" {
+			if matched, _ := filepath.Match(onlyFlag, filepath.Base(filename)); !matched {
+				continue
+			}
+		}
+		if twoColFlag {
+			sb.WriteString(fmt.Sprintf(format, index, filename+twoColSep+filename))
+		} else {
+			sb.WriteString(fmt.Sprintf(format, index, filename))
+		}
+	}
+	return sb.String()
+}
+
+// twoColSep separates the old and new name in -two-col lines.
+const twoColSep = " => "
+
+// splitTwoCol replaces each "old => new" value in renames with the new name.
+// The old column is informational; if it no longer matches the file at that
+// index a warning is printed and the edit is still applied by index.
+func splitTwoCol(files []string, renames map[int]string) error {
+	for index, value := range renames {
+		if update, ok := strings.CutPrefix(value, files[index]+twoColSep); ok {
+			renames[index] = update
+			continue
+		}
+		old, update, ok := strings.Cut(value, twoColSep)
+		if !ok {
+			return fmt.Errorf("%d: missing %q between the old and new name", index, strings.TrimSpace(twoColSep))
+		}
+		fmt.Fprintf(os.Stderr, "warning: %d: old name changed from %s to %s, ignoring it\n", index, files[index], old)
+		renames[index] = update
+	}
+	return nil
+}
+
+// applyRenames parses contents in the edit format and renames the files.
+func applyRenames(files []string, contents string) error {
+	renames, err := parseRenames(len(files)-1, contents)
 	if err != nil {
-		return fmt.Errorf("error parsing mvit tempfile: %w", err)
+		return fmt.Errorf("error parsing renames: %w", err)
+	}
+	if twoColFlag {
+		if err = splitTwoCol(files, renames); err != nil {
+			return fmt.Errorf("error parsing renames: %w", err)
+		}
 	}
 
+	if err = confirmRenames(countRenames(files, renames)); err != nil {
+		return err
+	}
 	return rename(files, renames)
 }
 
+// countRenames returns how many files would get a new name.
+func countRenames(files []string, renames map[int]string) (count int) {
+	for index, update := range renames {
+		if update != files[index] {
+			count++
+		}
+	}
+	return
+}
+
+// confirmRenames asks before renaming more than -yes-threshold files, unless -y
+// or -confirm-each is given.
+// Without a terminal to ask on, or with -i=false, it refuses instead of waiting for input.
+func confirmRenames(count int) error {
+	if yesFlag || confirmEachFlag || yesThreshold <= 0 || count <= yesThreshold {
+		return nil
+	}
+	if !interactiveFlag || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("refusing to rename %d files without confirmation, use -y", count)
+	}
+	var response string
+	fmt.Printf("About to rename %d files, continue? [y/N] ", count)
+	fmt.Scanln(&response)
+	if response != "y" && response != "Y" {
+		return errors.New("aborted")
+	}
+	return nil
+}
+
 // dedupe removes duplicate filenames from the list.
 func dedupe(filenames []string) []string {
 	uniqueFilenames := make(map[string]bool)
@@ -207,6 +405,14 @@ func main() {
 	if changeFlag {
 		verboseFlag = false
 	}
+	if trashDirFlag != "" {
+		trashFlag = true
+	}
+
+	if _, err := filepath.Match(onlyFlag, ""); err != nil {
+		fmt.Printf("invalid -only pattern %q: %s\n", onlyFlag, err)
+		os.Exit(2)
+	}
 
 	filenames := flag.Args()
 	if len(filenames) == 0 {
@@ -215,6 +421,10 @@ func main() {
 	}
 
 	filenames = dedupe(filenames)
+	if err := sortFiles(filenames, sortFlag); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 
 	err := mvit(filenames)
 	if err != nil {
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRenameCaseOnly(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "File.txt")
+	to := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(from, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !sameFile(from, to) {
+		t.Skip("filesystem is case-sensitive")
+	}
+
+	if err := rename([]string{from}, map[int]string{0: to}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Fatalf("expected only file.txt after rename, got %v", entries)
+	}
+}
+
+func TestRenameOntoSymlinkTarget(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "b.txt")
+	link := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(target, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b.txt", link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := rename([]string{link}, map[int]string{0: target}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Fatalf("expected %s to remain a regular file, got mode %s", target, info.Mode())
+	}
+	if _, err := os.Lstat(link); err != nil {
+		t.Fatalf("expected symlink to be left in place: %v", err)
+	}
+}
+
+func TestSortFilesNumeric(t *testing.T) {
+	files := []string{"file10", "file2", "file1", "file02b", "a"}
+	if err := sortFiles(files, "numeric"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "file1", "file2", "file02b", "file10"}
+	if !slices.Equal(files, want) {
+		t.Fatalf("got %v, want %v", files, want)
+	}
+}
+
+func TestConfirmRenamesThreshold(t *testing.T) {
+	defer func(yes bool, threshold int, interactive bool) {
+		yesFlag, yesThreshold, interactiveFlag = yes, threshold, interactive
+	}(yesFlag, yesThreshold, interactiveFlag)
+	yesFlag, yesThreshold, interactiveFlag = false, 2, false
+
+	files := []string{"a", "b", "c"}
+	renames := map[int]string{0: "x", 1: "y", 2: "c"}
+	if err := confirmRenames(countRenames(files, renames)); err != nil {
+		t.Fatalf("expected 2 renames to pass a threshold of 2: %v", err)
+	}
+	renames[2] = "z"
+	if err := confirmRenames(countRenames(files, renames)); err == nil {
+		t.Fatal("expected 3 renames to be refused without a terminal")
+	}
+	yesFlag = true
+	if err := confirmRenames(countRenames(files, renames)); err != nil {
+		t.Fatalf("expected -y to skip confirmation: %v", err)
+	}
+}
+
+func TestTransformRenames(t *testing.T) {
+	defer func(prefix, suffix, strip string) {
+		prefixFlag, suffixFlag, stripPrefixFlag = prefix, suffix, strip
+	}(prefixFlag, suffixFlag, stripPrefixFlag)
+	prefixFlag, suffixFlag, stripPrefixFlag = "new-", "_v2", "old-"
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "old-a.txt"), filepath.Join(dir, ".rc"), filepath.Join(dir, "b")}
+	_, renames, err := transformRenames(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{
+		0: filepath.Join(dir, "new-a_v2.txt"),
+		1: filepath.Join(dir, "new-.rc_v2"),
+		2: filepath.Join(dir, "new-b_v2"),
+	}
+	for index, name := range want {
+		if renames[index] != name {
+			t.Errorf("renames[%d] = %q, want %q", index, renames[index], name)
+		}
+	}
+
+	files = append(files, filepath.Join(dir, "a.txt"))
+	if _, _, err := transformRenames(files); err == nil {
+		t.Fatal("expected old-a.txt and a.txt to collide")
+	}
+}
+
+func TestTransformRenamesChain(t *testing.T) {
+	defer func(suffix string) { suffixFlag = suffix }(suffixFlag)
+	suffixFlag = "_v2"
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "b"), filepath.Join(dir, "b_v2")}
+	for _, filename := range files {
+		if err := os.WriteFile(filename, []byte(filepath.Base(filename)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// b_v2 is renamed too, so b may take its name once it has moved.
+	ordered, renames, err := transformRenames(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rename(ordered, renames); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"b_v2": "b", "b_v2_v2": "b_v2"} {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != want {
+			t.Errorf("%s holds %q, %v, want %q", name, b, err, want)
+		}
+	}
+	if exists(files[0]) {
+		t.Error("expected b to be renamed")
+	}
+}
+
+func TestRenameTrashesOverwrittenFile(t *testing.T) {
+	defer func(trashOn bool, dir string, interactive bool) {
+		trashFlag, trashDirFlag, interactiveFlag = trashOn, dir, interactive
+	}(trashFlag, trashDirFlag, interactiveFlag)
+	dir := t.TempDir()
+	trashFlag, trashDirFlag, interactiveFlag = true, filepath.Join(dir, "trash"), false
+
+	from := filepath.Join(dir, "new.txt")
+	to := filepath.Join(dir, "old.txt")
+	if err := os.WriteFile(from, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(to, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rename([]string{from}, map[int]string{0: to}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	if b, err := os.ReadFile(to); err != nil || string(b) != "new" {
+		t.Fatalf("expected %s to hold the renamed file, got %q, %v", to, b, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(trashDirFlag, "old.txt")); err != nil || string(b) != "old" {
+		t.Fatalf("expected the overwritten file in the trash, got %q, %v", b, err)
+	}
+}
+
+func TestEditTemplateOnly(t *testing.T) {
+	defer func(only string) { onlyFlag = only }(onlyFlag)
+	onlyFlag = "*.jpg"
+
+	files := []string{"a.txt", "dir/b.jpg", "c.png", "d.jpg"}
+	want := "1: dir/b.jpg\n3: d.jpg\n"
+	if got := editTemplate(files); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	renames, err := parseRenames(len(files)-1, "1: dir/x.jpg\n3: d.jpg\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countRenames(files, renames); n != 1 {
+		t.Fatalf("expected only dir/b.jpg to be renamed, got %d renames", n)
+	}
+}
+
+func TestParseRenamesOutOfRange(t *testing.T) {
+	for _, contents := range []string{"-1: x\n", "3: x\n"} {
+		if _, err := parseRenames(2, contents); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("%q: got %v, want an out of range error", contents, err)
+		}
+	}
+}
+
+func TestSplitTwoCol(t *testing.T) {
+	defer func(twoCol bool) { twoColFlag = twoCol }(twoColFlag)
+	twoColFlag = true
+
+	files := []string{"a => b.txt", "c.txt", "d.txt"}
+	template := editTemplate(files)
+	if want := "0: a => b.txt => a => b.txt\n1: c.txt => c.txt\n2: d.txt => d.txt\n"; template != want {
+		t.Fatalf("editTemplate = %q, want %q", template, want)
+	}
+	renames, err := parseRenames(len(files)-1, "0: a => b.txt => e.txt\n1: c.txt => c.txt\n2: typo.txt => f.txt\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = splitTwoCol(files, renames); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{0: "e.txt", 1: "c.txt", 2: "f.txt"}
+	for index, name := range want {
+		if renames[index] != name {
+			t.Errorf("renames[%d] = %q, want %q", index, renames[index], name)
+		}
+	}
+
+	if err = splitTwoCol(files, map[int]string{1: "g.txt"}); err == nil {
+		t.Fatal("expected a line without => to be rejected")
+	}
+}
+
+func TestApplyRenamesTwoColOutOfRange(t *testing.T) {
+	defer func(twoCol bool) { twoColFlag = twoCol }(twoColFlag)
+	twoColFlag = true
+
+	// The index is checked before the old column is compared with files.
+	err := applyRenames([]string{"a"}, "-1: a => b\n")
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatalf("got %v, want an out of range error", err)
+	}
+}
+
+func TestRenameConfirmEach(t *testing.T) {
+	defer func(each, yes bool, in io.Reader) {
+		confirmEachFlag, yesFlag, confirmIn = each, yes, in
+	}(confirmEachFlag, yesFlag, confirmIn)
+	confirmEachFlag, yesFlag = true, false
+
+	dir := t.TempDir()
+	var files []string
+	renames := map[int]string{}
+	for i, name := range []string{"a", "b", "c", "d"} {
+		files = append(files, filepath.Join(dir, name))
+		renames[i] = filepath.Join(dir, name+".new")
+		if err := os.WriteFile(files[i], nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Skip a, rename b after an unknown answer, then stdin ends: c and d stay.
+	confirmIn = strings.NewReader("n\nmaybe\ny\n")
+	if err := rename(files, renames); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{false, true, false, false} {
+		if exists(renames[i]) != want {
+			t.Errorf("%s renamed = %v, want %v", files[i], !want, want)
+		}
+	}
+
+	// all renames every remaining file without asking again.
+	confirmIn = strings.NewReader("a\n")
+	delete(renames, 1)
+	if err := rename(files, renames); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if !exists(renames[i]) {
+			t.Errorf("expected %s to be renamed after all", files[i])
+		}
+	}
+}
+
+func TestApplyRenames(t *testing.T) {
+	defer func(yes bool) { yesFlag = yes }(yesFlag)
+	yesFlag = true
+
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a", "b"} {
+		files = append(files, filepath.Join(dir, name))
+		if err := os.WriteFile(files[len(files)-1], []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	renamed := filepath.Join(dir, "c")
+
+	// Bad input is refused before any file is touched.
+	for _, contents := range []string{
+		"0: " + renamed + "\n2: " + renamed + "\n",
+		"0: " + renamed + "\nno index here\n",
+	} {
+		if err := applyRenames(files, contents); err == nil {
+			t.Errorf("%q: expected an error", contents)
+		}
+		if !exists(files[0]) || exists(renamed) {
+			t.Fatalf("%q: files were renamed despite the error", contents)
+		}
+	}
+
+	if err := applyRenames(files, "0: "+renamed+"\n1: "+files[1]+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(renamed); err != nil || string(b) != "a" || exists(files[0]) || !exists(files[1]) {
+		t.Fatalf("expected only a to be renamed to c, got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// naturalCompare compares strings treating runs of digits as numbers,
+// so "file2" sorts before "file10".
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aNum, bNum := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+			if c := cmp.Or(cmp.Compare(len(aNum), len(bNum)), strings.Compare(aNum, bNum)); c != 0 {
+				return c
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+		if c := cmp.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		a, b = a[1:], b[1:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// leadingDigits returns the run of ASCII digits at the start of s.
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// sortFiles orders filenames in place by the given method
+// (name, numeric, mtime or size). Files that cannot be stat'ed sort first.
+func sortFiles(filenames []string, method string) error {
+	switch method {
+	case "":
+	case "name":
+		slices.Sort(filenames)
+	case "numeric":
+		slices.SortStableFunc(filenames, naturalCompare)
+	case "mtime", "size":
+		infos := make(map[string]os.FileInfo, len(filenames))
+		for _, filename := range filenames {
+			if info, err := os.Stat(filename); err == nil {
+				infos[filename] = info
+			}
+		}
+		key := func(filename string) int64 {
+			info, ok := infos[filename]
+			switch {
+			case !ok:
+				return -1
+			case method == "size":
+				return info.Size()
+			default:
+				return info.ModTime().UnixNano()
+			}
+		}
+		slices.SortStableFunc(filenames, func(a, b string) int {
+			return cmp.Compare(key(a), key(b))
+		})
+	default:
+		return fmt.Errorf("unknown sort method: %s", method)
+	}
+	return nil
+}
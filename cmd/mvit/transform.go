@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"al.essio.dev/pkg/shellescape"
+)
+
+// transforming reports whether any name transform flag was given, in which
+// case the renames are computed instead of edited.
+func transforming() bool {
+	return prefixFlag != "" || suffixFlag != "" || stripPrefixFlag != ""
+}
+
+// transformName applies -strip-prefix, -prefix and -suffix to the base name
+// of filename. The suffix goes before the extension; the directory is kept.
+func transformName(filename string) string {
+	dir, base := filepath.Split(filename)
+	base = strings.TrimPrefix(base, stripPrefixFlag)
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = "" // dotfile such as .bashrc
+	}
+	return dir + prefixFlag + strings.TrimSuffix(base, ext) + suffixFlag + ext
+}
+
+// transformRenames builds the rename map for files from the transform flags.
+// Collisions are checked against the names once every rename is done, so a
+// chain such as a -> b, b -> c is allowed; the files are returned reordered
+// so that b is moved out of the way before a takes its name. It fails,
+// listing every collision, if two files would get the same name, a new name
+// is taken by a file that is not renamed, or the renames form a cycle.
+func transformRenames(files []string) ([]string, map[int]string, error) {
+	updates := make(map[int]string, len(files))
+	sources := make(map[string]int, len(files))
+	for index, filename := range files {
+		if update := transformName(filename); update != filename {
+			updates[index] = update
+			sources[filename] = index
+		}
+	}
+
+	targets := make(map[string]string, len(updates))
+	var collisions []string
+	for index, filename := range files {
+		update, ok := updates[index]
+		if !ok {
+			continue
+		}
+		if other, ok := targets[update]; ok {
+			collisions = append(collisions, fmt.Sprintf("`%s' and `%s' both become `%s'", shellescape.Quote(other), shellescape.Quote(filename), shellescape.Quote(update)))
+		} else if _, moved := sources[update]; !moved && exists(update) && !sameFile(filename, update) {
+			collisions = append(collisions, fmt.Sprintf("`%s' -> `%s' already exists", shellescape.Quote(filename), shellescape.Quote(update)))
+		}
+		targets[update] = filename
+	}
+
+	// Each rename goes after the rename of the file holding its new name.
+	order := make([]int, 0, len(files))
+	const visiting, done = 1, 2
+	state := make(map[int]int, len(files))
+	var visit func(index int)
+	visit = func(index int) {
+		state[index] = visiting
+		if next, ok := sources[updates[index]]; ok {
+			switch state[next] {
+			case visiting:
+				collisions = append(collisions, fmt.Sprintf("`%s' -> `%s' is part of a cycle", shellescape.Quote(files[index]), shellescape.Quote(updates[index])))
+			case 0:
+				visit(next)
+			}
+		}
+		state[index] = done
+		order = append(order, index)
+	}
+	for index := range files {
+		if state[index] == 0 {
+			visit(index)
+		}
+	}
+	if len(collisions) > 0 {
+		return nil, nil, errors.New("name collisions:\n  " + strings.Join(collisions, "\n  "))
+	}
+
+	ordered := make([]string, len(files))
+	renames := make(map[int]string, len(updates))
+	for i, index := range order {
+		ordered[i] = files[index]
+		if update, ok := updates[index]; ok {
+			renames[i] = update
+		}
+	}
+	return ordered, renames, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// localTrashDir is used next to the file when the XDG trash is on another filesystem.
+const localTrashDir = ".mvit-trash"
+
+// trashName returns a name in dir for base that does not exist yet.
+func trashName(dir, base string) string {
+	name := filepath.Join(dir, base)
+	for i := 1; exists(name); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s.%d", base, i))
+	}
+	return name
+}
+
+// moveToDir moves filename into dir, creating dir if needed.
+func moveToDir(filename, dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return os.Rename(filename, trashName(dir, filepath.Base(filename)))
+}
+
+// xdgTrash returns the home trash directory of the XDG trash specification.
+func xdgTrash() (string, error) {
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return filepath.Join(data, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// trash moves filename out of the way so it can be recovered after being
+// replaced. With -trash-dir it goes there; otherwise to the XDG trash, with a
+// .trashinfo record, or, if that is on another filesystem, to a .mvit-trash
+// directory next to the file.
+func trash(filename string) error {
+	if trashDirFlag != "" {
+		return moveToDir(filename, trashDirFlag)
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	root, err := xdgTrash()
+	if err != nil {
+		return err
+	}
+	filesDir, infoDir := filepath.Join(root, "files"), filepath.Join(root, "info")
+	if err = os.MkdirAll(filesDir, 0o700); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(infoDir, 0o700); err != nil {
+		return err
+	}
+	target := trashName(filesDir, filepath.Base(abs))
+	info := filepath.Join(infoDir, filepath.Base(target)+".trashinfo")
+	record := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	if err = os.WriteFile(info, []byte(record), 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(abs, target); err != nil {
+		os.Remove(info)
+		if errors.Is(err, syscall.EXDEV) {
+			return moveToDir(abs, filepath.Join(filepath.Dir(abs), localTrashDir))
+		}
+		return err
+	}
+	return nil
+}
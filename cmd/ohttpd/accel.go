@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// accelHeader is the upstream response header naming an internal file to serve instead.
+const accelHeader = "X-Accel-Redirect"
+
+// accelMount is the internal location X-Accel-Redirect paths are resolved in,
+// like an nginx location marked internal. It is not reachable by clients.
+type accelMount struct {
+	prefix string
+	dir    string
+}
+
+// accelRedirect is set by -accel-redirect and applies to every proxy mount.
+var accelRedirect *accelMount
+
+// parseAccelMount parses "prefix:dir", e.g. "/protected/:/srv/files".
+func parseAccelMount(value string) (*accelMount, error) {
+	prefix, dir, ok := strings.Cut(value, ":")
+	if !ok || !strings.HasPrefix(prefix, "/") || dir == "" {
+		return nil, fmt.Errorf("invalid -accel-redirect %q, expected /prefix/:dir", value)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &accelMount{prefix: prefix, dir: dir}, nil
+}
+
+// accelFile is returned from modifyResponse to hand the request over to
+// errorHandler, which has the ResponseWriter needed to serve the file.
+type accelFile struct {
+	filename string
+}
+
+func (a *accelFile) Error() string { return accelHeader + " to " + a.filename }
+
+// errAccelNotFound is reported for X-Accel-Redirect paths outside of the internal prefix.
+var errAccelNotFound = errors.New("X-Accel-Redirect outside of internal location")
+
+// modifyResponse intercepts upstream responses carrying X-Accel-Redirect.
+func (m *accelMount) modifyResponse(resp *http.Response) error {
+	target := resp.Header.Get(accelHeader)
+	if target == "" {
+		return nil
+	}
+	rest, ok := strings.CutPrefix(path.Clean("/"+target), strings.TrimSuffix(m.prefix, "/"))
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return fmt.Errorf("%s %s: %w", accelHeader, target, errAccelNotFound)
+	}
+	return &accelFile{filepath.Join(m.dir, filepath.FromSlash(rest))}
+}
+
+// errorHandler serves the internal file for an X-Accel-Redirect and otherwise
+// behaves like the ReverseProxy default.
+func (m *accelMount) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if file, ok := errors.AsType[*accelFile](err); ok {
+		http.ServeFile(w, r, file.filename)
+		return
+	}
+	log.Printf("http: proxy error: %v", err)
+	if errors.Is(err, errAccelNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+)
+
+// printBanner writes a summary of the listen address, TLS status and every
+// resolved route so it is easy to check the command line parsed as intended.
+func printBanner(w io.Writer, mounts map[string]*Mount) {
+	tls := "off"
+	if certFlag != "" && keyFlag != "" {
+		tls = "on (" + certFlag + ")"
+	}
+	addr := listenFlag
+	if socketActivated() {
+		addr = "a systemd socket"
+	}
+	fmt.Fprintf(w, "ohttpd %s listening on %s, TLS %s\n", version, addr, tls)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  PATH\tSOURCE\tREWRITE")
+	for _, path := range slices.Sorted(maps.Keys(mounts)) {
+		mnt := mounts[path]
+		fmt.Fprintf(tw, "  %s\t%s\t%t\n", mnt.Path, mnt.Source, mnt.Rewrite)
+	}
+	tw.Flush()
+}
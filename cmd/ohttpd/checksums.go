@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// checksumsDir is the path under each file mount that serves checksums.
+const checksumsDir = ".checksums/"
+
+// checksummer holds the hash server and cache shared by every checksum handler.
+type checksummer struct {
+	srv   xsum.Server
+	cache xsum.Cache
+}
+
+// mountChecksummer is set when -checksums is enabled.
+var mountChecksummer *checksummer
+
+func newChecksummer() (*checksummer, error) {
+	srv, err := xsum.NewServer("sha256")
+	if err != nil {
+		return nil, err
+	}
+	return &checksummer{srv: srv, cache: xsum.NewMemoryCache()}, nil
+}
+
+// checksumHandler serves the sha256 of files under a file mount in sha256sum format.
+type checksumHandler struct {
+	mount *Mount
+	*checksummer
+}
+
+func (h *checksumHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel := strings.TrimPrefix(r.URL.Path, path.Join(h.mount.Path, checksumsDir)+"/")
+	if !h.mount.Rewrite {
+		rel = path.Join(h.mount.Path, rel)
+	}
+	if !followSymlinksFlag && checkNoSymlink(h.mount.Source.Path, rel) != nil {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	filename := h.mount.localPath(rel)
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	sums, err := xsum.HashFile(h.srv, h.cache, filename)
+	if err != nil {
+		http.Error(w, "failed to compute checksum", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%x  %s\n", sums["sha256"], path.Base(rel))
+}
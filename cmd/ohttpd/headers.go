@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// secureHeaders is the -secure-headers preset.
+var secureHeaders = map[string]string{
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "DENY",
+	"Referrer-Policy":         "no-referrer",
+	"Content-Security-Policy": "default-src 'self'; object-src 'none'; frame-ancestors 'none'",
+}
+
+// headerFlags collects repeated -H "Name: value" flags.
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	var sb strings.Builder
+	for name, values := range h {
+		for _, value := range values {
+			fmt.Fprintf(&sb, "%s: %s\n", name, value)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (h headerFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected Name: value, got %q", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// withHeaders sets the -secure-headers preset (if enabled) and then the
+// custom -H headers on every response, so a custom header replaces a preset one.
+func withHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secureHeadersFlag {
+			for name, value := range secureHeaders {
+				w.Header().Set(name, value)
+			}
+		}
+		for name, values := range customHeaders {
+			// A copy, so inner handlers changing the header cannot touch
+			// the configured values shared by every request.
+			w.Header()[name] = slices.Clone(values)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithHeadersCopiesValues(t *testing.T) {
+	customHeaders["X-Test"] = make([]string, 1, 4)
+	customHeaders["X-Test"][0] = "configured"
+	defer delete(customHeaders, "X-Test")
+
+	h := withHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["X-Test"][0] = "changed"
+		w.Header().Add("X-Test", "added")
+	}))
+	for range 2 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := rec.Header().Values("X-Test"); len(got) != 2 || got[0] != "changed" || got[1] != "added" {
+			t.Errorf("response header %q", got)
+		}
+	}
+	if got := customHeaders["X-Test"]; len(got) != 1 || got[0] != "configured" || got[:2][1] != "" {
+		t.Errorf("configured header changed to %q", got[:cap(got)])
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// injectFlags collects repeated -inject KEY=VALUE flags.
+type injectFlags map[string]string
+
+func (f injectFlags) String() string {
+	var pairs []string
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f injectFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", s)
+	}
+	f[key] = value
+	return nil
+}
+
+// replacer returns a Replacer substituting ${KEY} for each value.
+func (f injectFlags) replacer() *strings.Replacer {
+	var oldnew []string
+	for key, value := range f {
+		oldnew = append(oldnew, "${"+key+"}", value)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// isHTML reports whether a Content-Type is text/html.
+func isHTML(ctype string) bool {
+	mediaType, _, _ := mime.ParseMediaType(ctype)
+	return mediaType == "text/html"
+}
+
+// injectHandler substitutes ${KEY} tokens in text/html responses from a file
+// mount. HTML responses are buffered in full to do so; other responses are
+// written through unchanged.
+type injectHandler struct {
+	replacer *strings.Replacer
+	next     http.Handler
+}
+
+func (h *injectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// A range of the original file would not line up with the substituted
+	// body, so HTML is always served whole, and HEAD is served as GET so the
+	// Content-Length of the substituted body is known.
+	req := r
+	if strings.HasSuffix(r.URL.Path, "/") || isHTML(mime.TypeByExtension(path.Ext(r.URL.Path))) {
+		req = r.Clone(r.Context())
+		req.Header.Del("Range")
+		if req.Method == http.MethodHead {
+			req.Method = http.MethodGet
+		}
+	}
+	iw := &injectWriter{ResponseWriter: w}
+	h.next.ServeHTTP(iw, req)
+	if iw.buf != nil {
+		body := h.replacer.Replace(iw.buf.String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(iw.status)
+		if r.Method != http.MethodHead {
+			w.Write([]byte(body))
+		}
+	}
+}
+
+// injectWriter buffers a successful text/html response and passes any other
+// response through.
+type injectWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int
+	buf         *bytes.Buffer
+}
+
+func (w *injectWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code == http.StatusOK && isHTML(w.Header().Get("Content-Type")) {
+		w.status = code
+		w.buf = &bytes.Buffer{}
+		w.Header().Del("Content-Length")
+		w.Header().Del("Accept-Ranges")
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *injectWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buf != nil {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestInjectHandler(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"page.html": "<p>version ${VERSION}</p>",
+		"app.css":   "/* ${VERSION} */",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vars := injectFlags{}
+	if err := vars.Set("VERSION=1.2.3-long"); err != nil {
+		t.Fatal(err)
+	}
+	h := &injectHandler{vars.replacer(), http.FileServer(http.Dir(dir))}
+
+	for _, tc := range []struct {
+		method, path, rangeHeader, want string
+	}{
+		{http.MethodGet, "/page.html", "", "<p>version 1.2.3-long</p>"},
+		// A range of the file is answered with the whole substituted page.
+		{http.MethodGet, "/page.html", "bytes=0-3", "<p>version 1.2.3-long</p>"},
+		{http.MethodHead, "/page.html", "", ""},
+		{http.MethodGet, "/app.css", "", "/* ${VERSION} */"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.rangeHeader != "" {
+			req.Header.Set("Range", tc.rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+			t.Errorf("%s %s: got %d %q, want 200 %q", tc.method, tc.path, rec.Code, rec.Body.String(), tc.want)
+		}
+		wantLen := len(tc.want)
+		if tc.method == http.MethodHead {
+			wantLen = len("<p>version 1.2.3-long</p>")
+		}
+		if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(wantLen) {
+			t.Errorf("%s %s: Content-Length %s, want %d", tc.method, tc.path, got, wantLen)
+		}
+	}
+}
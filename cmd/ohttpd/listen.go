@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// unixPrefix marks a listen address as a Unix domain socket path.
+const unixPrefix = "unix:"
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+// socketActivated reports whether systemd passed this process listening
+// sockets, following the sd_listen_fds protocol: LISTEN_PID names this
+// process and LISTEN_FDS counts the descriptors starting at 3.
+func socketActivated() bool {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return false
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return err == nil && n > 0
+}
+
+// activatedListener returns a listener on the first socket passed by systemd.
+// The activation variables are cleared so they are not inherited.
+func activatedListener() (net.Listener, error) {
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if n > 1 {
+		log.Printf("Socket activation passed %d sockets, serving only the first", n)
+	}
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		os.Unsetenv(key)
+	}
+	f := os.NewFile(listenFDsStart, "systemd socket")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+	return l, nil
+}
+
+// parseSocketMode parses an octal file mode such as "0660".
+func parseSocketMode(s string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return fs.FileMode(mode) & fs.ModePerm, nil
+}
+
+// listen opens the listener for listenFlag, or uses the socket passed by
+// systemd socket activation, in which case -l is ignored.
+// Addresses prefixed with "unix:" bind a Unix domain socket, replacing a stale
+// socket file left behind by a previous run and applying -socket-mode if set.
+// The socket file is removed again when the listener is closed.
+func listen() (net.Listener, error) {
+	if socketActivated() {
+		return activatedListener()
+	}
+	socketPath, ok := strings.CutPrefix(listenFlag, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", listenFlag)
+	}
+	if info, err := os.Lstat(socketPath); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(socketPath); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if socketModeFlag != "" {
+		mode, err := parseSocketMode(socketModeFlag)
+		if err == nil {
+			err = os.Chmod(socketPath, mode)
+		}
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
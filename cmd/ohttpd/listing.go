@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dirSorts compares directory entries for each -dir-sort order. Names sort
+// ascending; sizes and modification times sort largest and newest first.
+var dirSorts = map[string]func(a, b fs.FileInfo) int{
+	"name": func(a, b fs.FileInfo) int {
+		return strings.Compare(a.Name(), b.Name())
+	},
+	"size": func(a, b fs.FileInfo) int {
+		return cmp.Compare(b.Size(), a.Size())
+	},
+	"mtime": func(a, b fs.FileInfo) int {
+		return b.ModTime().Compare(a.ModTime())
+	},
+}
+
+// dirListHandler renders directory listings sorted by -dir-sort, or by the
+// ?sort= query parameter when it names a known order. Directories are always
+// listed before files. Requests with no sort order, for anything other than a
+// directory, or for a directory with an index.html are left to next.
+type dirListHandler struct {
+	mount *Mount
+	next  http.Handler
+}
+
+func (h *dirListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	order := dirSortFlag
+	if _, ok := dirSorts[r.URL.Query().Get("sort")]; ok {
+		order = r.URL.Query().Get("sort")
+	}
+	if order == "" || !strings.HasSuffix(r.URL.Path, "/") || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	dir := h.mount.localPath(r.URL.Path)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.html")); err == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		// Entries removed since ReadDir are left out.
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	sortEntries(infos, order)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprintf(w, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: name}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\t%d\t%s\n",
+			link.String(), html.EscapeString(name), info.Size(), info.ModTime().UTC().Format(time.DateTime))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+}
+
+// sortEntries sorts infos by order with directories first; entries that
+// compare equal keep name order.
+func sortEntries(infos []fs.FileInfo, order string) {
+	byOrder := dirSorts[order]
+	slices.SortStableFunc(infos, func(a, b fs.FileInfo) int {
+		if a.IsDir() != b.IsDir() {
+			if a.IsDir() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(byOrder(a, b), strings.Compare(a.Name(), b.Name()))
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirListHandler(t *testing.T) {
+	defer func(order string) { dirSortFlag = order }(dirSortFlag)
+
+	dir := t.TempDir()
+	now := time.Now()
+	for name, file := range map[string]struct {
+		size int
+		age  time.Duration
+	}{
+		"big":     {100, 2 * time.Hour},
+		"small":   {1, 0},
+		"a&b.txt": {10, time.Hour},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", file.size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now, now.Add(-file.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sub := range []string{"zdir", "site"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "site", "index.html"), []byte("home"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mount := &Mount{Path: "/", Source: &url.URL{Scheme: "file", Path: dir}}
+	h := &dirListHandler{mount, http.FileServer(http.Dir(dir))}
+	get := func(target string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	link := regexp.MustCompile(`<a href="([^"]*)">([^<]*)</a>\t`)
+	listed := func(body string) (names []string) {
+		for _, m := range link.FindAllStringSubmatch(body, -1) {
+			names = append(names, m[2])
+		}
+		return names
+	}
+
+	dirSortFlag = "name"
+	for target, want := range map[string][]string{
+		"/":            {"site/", "zdir/", "a&amp;b.txt", "big", "small"},
+		"/?sort=size":  {"site/", "zdir/", "big", "a&amp;b.txt", "small"},
+		"/?sort=mtime": {"site/", "zdir/", "small", "a&amp;b.txt", "big"},
+		"/?sort=bogus": {"site/", "zdir/", "a&amp;b.txt", "big", "small"},
+	} {
+		if got := listed(get(target)); !slices.Equal(got, want) {
+			t.Errorf("%s: listed %q, want %q", target, got, want)
+		}
+	}
+	// A directory with an index.html, and any request without a sort order,
+	// is left to the file server.
+	if got := get("/site/"); got != "home" {
+		t.Errorf("/site/: got %q, want the index page", got)
+	}
+	dirSortFlag = ""
+	if got := listed(get("/")); got != nil {
+		t.Errorf("without -dir-sort: listed %q, want the file server's listing", got)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -8,18 +11,44 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/ophymx/utils/httplog"
+	"golang.org/x/net/netutil"
 )
 
 var (
-	helpFlag    bool
-	versionFlag bool
-	listenFlag  string
-	keyFlag     string
-	certFlag    string
+	helpFlag           bool
+	versionFlag        bool
+	listenFlag         string
+	keyFlag            string
+	certFlag           string
+	configFlag         string
+	checksumsFlag      bool
+	socketModeFlag     string
+	sniffFlag          bool
+	dirSortFlag        string
+	noDefaultFlag      bool
+	maxConnsFlag       int
+	proxyCacheFlag     int
+	pprofFlag          string
+	accelFlag          string
+	proxyRetriesFlag   int
+	proxyBackoffFlag   time.Duration
+	keepAliveFlag      bool
+	noRobotsFlag       bool
+	secureHeadersFlag  bool
+	handlerTimeoutFlag time.Duration
+	streamMountsFlag   string
+	followSymlinksFlag bool
+	customHeaders      = headerFlags{}
+	injectVars         = injectFlags{}
 )
 
 const (
@@ -31,10 +60,30 @@ const (
 // init initializes the command-line flags and usage message.
 func init() {
 	flag.BoolVar(&helpFlag, "h", false, "Display help")
-	flag.StringVar(&listenFlag, "l", ":8080", "Listen address")
+	flag.StringVar(&listenFlag, "l", ":8080", "Listen address (unix:/path for a Unix domain socket)")
+	flag.StringVar(&socketModeFlag, "socket-mode", "", "File mode for a Unix domain socket, e.g. 0660")
 	flag.StringVar(&keyFlag, "k", "", "TLS key file (requires -c)")
 	flag.StringVar(&certFlag, "c", "", "TLS certificate file (requires -k)")
 	flag.BoolVar(&versionFlag, "V", false, "Display version")
+	flag.BoolVar(&checksumsFlag, "checksums", false, "Serve sha256 sums of files under /.checksums/ in each file mount")
+	flag.IntVar(&maxConnsFlag, "max-conns", 0, "Maximum concurrent connections, excess connections wait (0 for no limit)")
+	flag.StringVar(&accelFlag, "accel-redirect", "", "Serve files named by upstream X-Accel-Redirect headers from an internal `prefix:dir`, e.g. /protected/:/srv/files")
+	flag.StringVar(&pprofFlag, "pprof", "", "Serve /debug/pprof/ on a separate admin `address`, localhost unless a host is given (e.g. :6060)")
+	flag.IntVar(&proxyRetriesFlag, "proxy-retries", 0, "Retry GET and HEAD requests to proxy mounts this many times on connection errors")
+	flag.DurationVar(&proxyBackoffFlag, "proxy-retry-backoff", 100*time.Millisecond, "Delay before the first proxy retry, doubled for each further retry")
+	flag.IntVar(&proxyCacheFlag, "proxy-cache", 0, "Cache up to this many cacheable GET responses from proxy mounts (0 to disable)")
+	flag.DurationVar(&handlerTimeoutFlag, "handler-timeout", 0, "Answer 503 when a mount takes longer than this to respond (0 to disable); responses are buffered until done, so large downloads need -stream-mounts")
+	flag.StringVar(&streamMountsFlag, "stream-mounts", "", "Comma separated mount `paths` exempt from -handler-timeout, e.g. for downloads or event streams")
+	flag.BoolVar(&keepAliveFlag, "keep-alive", true, "Enable HTTP keep-alive")
+	flag.BoolVar(&secureHeadersFlag, "secure-headers", false, "Add nosniff, frame, referrer and content security policy headers")
+	flag.Var(customHeaders, "H", "Add a response header \"Name: value\" (repeatable, overrides -secure-headers)")
+	flag.Var(injectVars, "inject", "Replace ${KEY} with VALUE in HTML served from file mounts, given as KEY=VALUE (repeatable)")
+	flag.BoolVar(&noRobotsFlag, "no-robots", false, "Serve a disallow-all robots.txt and send X-Robots-Tag: noindex")
+	flag.StringVar(&dirSortFlag, "dir-sort", "", "Sort directory listings by `order` (name, size, mtime), directories first; ?sort= overrides it per request")
+	flag.BoolVar(&followSymlinksFlag, "follow-symlinks", true, "Follow symlinks in file mounts; with false, paths through a symlink are refused with 403")
+	flag.BoolVar(&sniffFlag, "sniff", false, "Detect Content-Type from file content when the extension does not give one")
+	flag.BoolVar(&noDefaultFlag, "no-default", false, "Require at least one mount instead of serving the current directory when none is given")
+	flag.StringVar(&configFlag, "f", "", "Mount config file, one mount per line (reloaded on SIGHUP)")
 	flag.Usage = func() {
 		fmt.Println(usage)
 		fmt.Println(description)
@@ -76,18 +125,47 @@ type Mount struct {
 func (m *Mount) mount(mux *http.ServeMux) {
 	var handler http.Handler
 	if m.Source.Scheme == "file" {
-		handler = http.FileServer(http.Dir(m.Source.Path))
+		var root http.FileSystem = http.Dir(m.Source.Path)
+		if !followSymlinksFlag {
+			root = noSymlinkDir(m.Source.Path)
+		}
+		handler = &dirListHandler{m, http.FileServer(root)}
+		if len(injectVars) > 0 {
+			handler = &injectHandler{injectVars.replacer(), handler}
+		}
+		if sniffFlag {
+			handler = &sniffHandler{m, handler}
+		}
+		if !followSymlinksFlag {
+			handler = &noSymlinkHandler{m, handler}
+		}
 	} else {
 		proxy := httputil.NewSingleHostReverseProxy(m.Source)
 		// Use logging transport for proxy requests
 		proxy.Transport = httplog.NewLoggingTransport()
+		if proxyRetriesFlag > 0 {
+			proxy.Transport = &retryTransport{proxyRetriesFlag, proxyBackoffFlag, proxy.Transport}
+		}
+		if proxyCache != nil {
+			proxy.Transport = &cachingTransport{proxyCache, proxy.Transport}
+		}
+		if accelRedirect != nil {
+			proxy.ModifyResponse = accelRedirect.modifyResponse
+			proxy.ErrorHandler = accelRedirect.errorHandler
+		}
 		handler = proxy
 	}
 	if m.Rewrite {
 		handler = http.StripPrefix(m.Path, handler)
 	}
+	if !isStreamMount(m.Path) {
+		handler = withTimeout(handler, handlerTimeoutFlag)
+	}
 	log.Printf("Mounting %s at %s", m.Source, m.Path)
 	mux.Handle(m.Path, handler)
+	if mountChecksummer != nil && m.Source.Scheme == "file" {
+		mux.Handle(path.Join(m.Path, checksumsDir)+"/", &checksumHandler{m, mountChecksummer})
+	}
 }
 
 // parseMount parses a mount string and returns a Mount struct.
@@ -155,6 +233,10 @@ func parseMounts(mountOptions []string) (mounts map[string]*Mount, err error) {
 			return nil, fmt.Errorf("invalid source scheme %s", mnt.Source.Scheme)
 		}
 
+		if !strings.HasPrefix(mnt.Path, "/") {
+			return nil, fmt.Errorf("invalid mount point %s", mnt.Path)
+		}
+
 		if _, ok := mounts[mnt.Path]; ok {
 			return nil, fmt.Errorf("duplicate mount point %s", mnt.Path)
 		}
@@ -164,6 +246,97 @@ func parseMounts(mountOptions []string) (mounts map[string]*Mount, err error) {
 	return mounts, nil
 }
 
+// readConfig reads mount options from a config file.
+// Each non-empty line is a mount in the same syntax as the command line;
+// lines starting with '#' are comments.
+func readConfig(filename string) (mountOptions []string, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		mountOptions = append(mountOptions, line)
+	}
+	return mountOptions, scanner.Err()
+}
+
+// loadMounts combines the command line mounts with those from the config file, if any.
+// With neither, the current directory is mounted at / unless -no-default is set.
+func loadMounts(mountArgs []string) (map[string]*Mount, error) {
+	mountOptions := mountArgs
+	if configFlag != "" {
+		configOptions, err := readConfig(configFlag)
+		if err != nil {
+			return nil, err
+		}
+		mountOptions = append(mountOptions[:len(mountOptions):len(mountOptions)], configOptions...)
+	}
+	if len(mountOptions) == 0 {
+		if noDefaultFlag {
+			return nil, errors.New("no mounts given")
+		}
+		mountOptions = append(mountOptions, ".")
+	}
+	return parseMounts(mountOptions)
+}
+
+// newMux creates a ServeMux with a handler for each mount point.
+// ServeMux panics on patterns it cannot register; that is reported as an error.
+func newMux(mounts map[string]*Mount) (mux *http.ServeMux, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	mux = http.NewServeMux()
+	for _, mnt := range mounts {
+		mnt.mount(mux)
+	}
+	if _, ok := mounts["/robots.txt"]; noRobotsFlag && !ok {
+		mux.Handle("/robots.txt", &robotsHandler{mounts["/"]})
+	}
+	return mux, nil
+}
+
+// reloadableHandler serves each request with the most recently stored mux,
+// so a reload applies to new requests while in-flight ones finish on the old one.
+type reloadableHandler struct {
+	current atomic.Value
+}
+
+func (h *reloadableHandler) Store(mux *http.ServeMux) { h.current.Store(mux) }
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().(*http.ServeMux).ServeHTTP(w, r)
+}
+
+// reloadOnHUP re-reads the mounts on every SIGHUP and swaps them into handler.
+// On error the current mounts are kept.
+func reloadOnHUP(handler *reloadableHandler, mountArgs []string) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	for range sigChan {
+		mounts, err := loadMounts(mountArgs)
+		if err != nil {
+			log.Printf("Reload failed, keeping current config: %s", err)
+			continue
+		}
+		mux, err := newMux(mounts)
+		if err != nil {
+			log.Printf("Reload failed, keeping current config: %s", err)
+			continue
+		}
+		handler.Store(mux)
+		log.Printf("Reloaded %s", configFlag)
+	}
+}
+
 func main() {
 	flag.Parse()
 
@@ -190,42 +363,107 @@ func main() {
 		return
 	}
 
-	mountArgs := flag.Args()
-	if len(mountArgs) == 0 {
-		mountArgs = append(mountArgs, ".")
+	if _, ok := dirSorts[dirSortFlag]; dirSortFlag != "" && !ok {
+		fmt.Printf("Error: invalid -dir-sort %s\n", dirSortFlag)
+		os.Exit(1)
+	}
+
+	if socketModeFlag != "" {
+		if _, err := parseSocketMode(socketModeFlag); err != nil {
+			fmt.Printf("Error: invalid -socket-mode %s\n", socketModeFlag)
+			os.Exit(1)
+		}
 	}
 
-	mounts, err := parseMounts(mountArgs)
+	mountArgs := flag.Args()
+	mounts, err := loadMounts(mountArgs)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err)
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	if err := serve(mounts); err != nil {
+	if checksumsFlag {
+		if mountChecksummer, err = newChecksummer(); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		defer mountChecksummer.srv.Close()
+	}
+
+	if accelFlag != "" {
+		if accelRedirect, err = parseAccelMount(accelFlag); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if proxyCacheFlag > 0 {
+		proxyCache = newResponseCache(proxyCacheFlag)
+	}
+
+	mux, err := newMux(mounts)
+	if err != nil {
 		fmt.Printf("Error: %s\n", err)
 		os.Exit(1)
 	}
-}
+	printBanner(os.Stderr, mounts)
+	handler := &reloadableHandler{}
+	handler.Store(mux)
+	if configFlag != "" {
+		go reloadOnHUP(handler, mountArgs)
+	}
 
-// serve starts the HTTP server with the given mounts.
-func serve(mounts map[string]*Mount) error {
-	// Create a new HTTP server
-	mux := http.NewServeMux()
-	// Add a handler for each mount point
-	for _, mnt := range mounts {
-		mnt.mount(mux)
+	if pprofFlag != "" {
+		go servePprof(pprofAddr(pprofFlag))
+	}
+
+	if err := serve(handler); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
 	}
+}
 
+// serve starts the HTTP server with the given handler.
+// It runs until SIGINT or SIGTERM, then closes the listener so that a Unix
+// socket file is cleaned up.
+func serve(handler http.Handler) error {
+	if noRobotsFlag {
+		handler = noIndex(handler)
+	}
+	if secureHeadersFlag || len(customHeaders) > 0 {
+		handler = withHeaders(handler)
+	}
 	server := &http.Server{
-		Addr:    listenFlag,
-		Handler: httplog.LogHandler(mux),
+		Handler: httplog.LogHandler(handler),
 	}
 
-	log.Printf("Listening on %s", listenFlag)
+	server.SetKeepAlivesEnabled(keepAliveFlag)
+
+	l, err := listen()
+	if err != nil {
+		return err
+	}
+	if maxConnsFlag > 0 {
+		l = netutil.LimitListener(l, maxConnsFlag)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Listening on %s", l.Addr())
 	// Start the server
 	if keyFlag != "" && certFlag != "" {
-		return server.ListenAndServeTLS(certFlag, keyFlag)
+		err = server.ServeTLS(l, certFlag, keyFlag)
+	} else {
+		err = server.Serve(l)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
-	return server.ListenAndServe()
+	return err
 }
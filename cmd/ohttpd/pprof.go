@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofAddr returns the admin listen address for -pprof. A bare port or an
+// empty host binds to localhost only.
+func pprofAddr(flagValue string) string {
+	host, port, err := net.SplitHostPort(flagValue)
+	if err != nil {
+		host, port = "", flagValue
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// servePprof serves the net/http/pprof handlers under /debug/pprof/ on addr.
+// It is a separate listener from the mounts and its requests are not logged.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	log.Printf("Serving pprof on http://%s/debug/pprof/", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("pprof: %s", err)
+	}
+}
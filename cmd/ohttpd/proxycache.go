@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedBody is the largest upstream response body kept by the proxy cache.
+const maxCachedBody = 1 << 20
+
+// proxyCache is shared by every proxy mount when -proxy-cache is set. It
+// outlives reloads so a SIGHUP does not empty it.
+var proxyCache *responseCache
+
+// cachedResponse is an upstream response held by responseCache.
+type cachedResponse struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	vary    map[string]string // request header values the response varies on
+	stored  time.Time
+	age     time.Duration // Age reported by upstream when stored
+	expires time.Time
+}
+
+// responseCache is a bounded LRU of cacheable GET responses keyed by URL.
+type responseCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns a fresh entry for req, or nil.
+func (c *responseCache) get(req *http.Request, now time.Time) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[req.URL.String()]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cachedResponse)
+	if !now.Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, entry.key)
+		return nil
+	}
+	for name, value := range entry.vary {
+		if req.Header.Get(name) != value {
+			return nil
+		}
+	}
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+func (c *responseCache) put(entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cacheControl parses a Cache-Control header into lower-case directives.
+func cacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// freshness returns how long resp may be served from a shared cache, or zero
+// if it must not be stored at all.
+func freshness(resp *http.Response) time.Duration {
+	cc := cacheControl(resp.Header)
+	for _, directive := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := cc[directive]; ok {
+			return 0
+		}
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := cc[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	expires, err := http.ParseTime(resp.Header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		date = time.Now()
+	}
+	return max(expires.Sub(date), 0)
+}
+
+// cachingTransport serves GET requests from cache when it can and stores
+// cacheable upstream responses.
+type cachingTransport struct {
+	cache *responseCache
+	next  http.RoundTripper
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" || req.Header.Get("Range") != "" {
+		return t.next.RoundTrip(req)
+	}
+	reqCC := cacheControl(req.Header)
+	_, noCache := reqCC["no-cache"]
+	_, noStore := reqCC["no-store"]
+
+	now := time.Now()
+	if !noCache && !noStore {
+		if entry := t.cache.get(req, now); entry != nil {
+			return entry.response(req, now), nil
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || noStore || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	ttl := freshness(resp)
+	if ttl <= 0 || resp.Header.Get("Set-Cookie") != "" || resp.Header.Get("Vary") == "*" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedBody {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &cachedResponse{
+		key:    req.URL.String(),
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+		body:   body,
+		vary:   make(map[string]string),
+		stored: now,
+	}
+	if age, err := strconv.Atoi(resp.Header.Get("Age")); err == nil && age > 0 {
+		entry.age = time.Duration(age) * time.Second
+	}
+	entry.expires = now.Add(ttl - entry.age)
+	for _, field := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(field, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				entry.vary[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+			}
+		}
+	}
+	t.cache.put(entry)
+	return resp, nil
+}
+
+// response builds a response to req from the entry, answering conditional
+// requests with 304 Not Modified when the validators match.
+func (entry *cachedResponse) response(req *http.Request, now time.Time) *http.Response {
+	header := entry.header.Clone()
+	header.Set("Age", strconv.Itoa(int((entry.age + now.Sub(entry.stored)).Seconds())))
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", entry.status, http.StatusText(entry.status)),
+		StatusCode: entry.status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Request:    req,
+	}
+	if notModified(req, entry.header) {
+		resp.StatusCode = http.StatusNotModified
+		resp.Status = fmt.Sprintf("%d %s", http.StatusNotModified, http.StatusText(http.StatusNotModified))
+		header.Del("Content-Length")
+		resp.Body = http.NoBody
+		return resp
+	}
+	resp.ContentLength = int64(len(entry.body))
+	resp.Body = io.NopCloser(bytes.NewReader(entry.body))
+	return resp
+}
+
+// notModified reports whether req's If-None-Match or If-Modified-Since is
+// satisfied by the cached response headers.
+func notModified(req *http.Request, header http.Header) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCachingTransport(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/stale":
+			// Already as old as it may get when it arrives.
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Age", "60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &cachingTransport{cache: newResponseCache(8), next: http.DefaultTransport}}
+	get := func(path string, header ...string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, upstream.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusOK && string(body) != path {
+			t.Errorf("%s: body %q", path, body)
+		}
+		return resp
+	}
+
+	for _, tc := range []struct {
+		path     string
+		upstream int32 // upstream requests for two GETs
+	}{
+		{"/fresh", 1},
+		{"/stale", 2},
+		{"/private", 2},
+	} {
+		hits.Store(0)
+		get(tc.path)
+		resp := get(tc.path)
+		if got := hits.Load(); got != tc.upstream {
+			t.Errorf("%s: %d upstream requests, want %d", tc.path, got, tc.upstream)
+		}
+		if resp.Status != "200 OK" {
+			t.Errorf("%s: status line %q", tc.path, resp.Status)
+		}
+	}
+
+	hits.Store(0)
+	resp := get("/fresh", "If-None-Match", `"v1"`)
+	if resp.StatusCode != http.StatusNotModified || resp.Status != "304 Not Modified" || hits.Load() != 0 {
+		t.Errorf("conditional hit: got %q after %d upstream requests", resp.Status, hits.Load())
+	}
+	if resp.Header.Get("Age") == "" {
+		t.Error("cached response has no Age header")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// retryTransport retries idempotent requests that fail before a response is
+// received, such as connection resets or refused connections. HTTP error
+// statuses are responses and are never retried.
+type retryTransport struct {
+	retries int
+	backoff time.Duration
+	next    http.RoundTripper
+}
+
+// retryable reports whether req may be sent again.
+func retryable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if !retryable(req) {
+		return resp, err
+	}
+	backoff := t.backoff
+	for attempt := 1; err != nil && attempt <= t.retries; attempt++ {
+		log.Printf("Retrying %s %s in %s (%d/%d): %s", req.Method, req.URL, backoff, attempt, t.retries, err)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		resp, err = t.next.RoundTrip(req)
+	}
+	return resp, err
+}
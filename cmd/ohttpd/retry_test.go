@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails the first failures round trips and then answers with
+// status, recording when each round trip was made.
+type flakyTransport struct {
+	failures int
+	status   int
+	calls    []time.Time
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls = append(t.calls, time.Now())
+	if len(t.calls) <= t.failures {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: t.status, Body: http.NoBody, Request: req}, nil
+}
+
+func TestRetryTransport(t *testing.T) {
+	const backoff = 5 * time.Millisecond
+	for _, tc := range []struct {
+		name      string
+		req       *http.Request
+		failures  int
+		status    int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"recovers", httptest.NewRequest(http.MethodGet, "/", nil), 2, http.StatusOK, 3, false},
+		{"gives up", httptest.NewRequest(http.MethodHead, "/", nil), 10, http.StatusOK, 4, true},
+		{"error status", httptest.NewRequest(http.MethodGet, "/", nil), 0, http.StatusBadGateway, 1, false},
+		{"post", httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x")), 10, http.StatusOK, 1, true},
+		{"get with body", httptest.NewRequest(http.MethodGet, "/", strings.NewReader("x")), 10, http.StatusOK, 1, true},
+	} {
+		next := &flakyTransport{failures: tc.failures, status: tc.status}
+		resp, err := (&retryTransport{3, backoff, next}).RoundTrip(tc.req)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+		}
+		if err == nil && resp.StatusCode != tc.status {
+			t.Errorf("%s: got status %d, want %d", tc.name, resp.StatusCode, tc.status)
+		}
+		if len(next.calls) != tc.wantCalls {
+			t.Errorf("%s: %d round trips, want %d", tc.name, len(next.calls), tc.wantCalls)
+		}
+		// The delay doubles before each retry.
+		for i := 1; i < len(next.calls); i++ {
+			if gap, want := next.calls[i].Sub(next.calls[i-1]), backoff<<(i-1); gap < want {
+				t.Errorf("%s: retry %d after %s, want at least %s", tc.name, i, gap, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"os"
+)
+
+// disallowAll is the generated robots.txt served with -no-robots.
+const disallowAll = "User-agent: *\nDisallow: /\n"
+
+// robotsHandler serves a disallow-all robots.txt unless the root file mount
+// has a robots.txt of its own, in which case that file is served.
+type robotsHandler struct {
+	root *Mount
+}
+
+func (h *robotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.root != nil && h.root.Source.Scheme == "file" {
+		filename := h.root.localPath("/robots.txt")
+		if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() &&
+			(followSymlinksFlag || checkNoSymlink(h.root.Source.Path, "/robots.txt") == nil) {
+			http.ServeFile(w, r, filename)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(disallowAll))
+}
+
+// noIndex adds X-Robots-Tag: noindex to every response.
+func noIndex(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "noindex")
+		next.ServeHTTP(w, r)
+	})
+}
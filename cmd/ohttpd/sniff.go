@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// localPath maps a URL path served by a file mount to a path on disk.
+func (m *Mount) localPath(urlPath string) string {
+	return filepath.Join(m.Source.Path, filepath.FromSlash(path.Clean("/"+urlPath)))
+}
+
+// sniffHandler sets Content-Type from the file content when the extension
+// gives no type or only application/octet-stream. The file is sniffed through
+// its own handle, so range requests served by next are unaffected.
+type sniffHandler struct {
+	mount *Mount
+	next  http.Handler
+}
+
+func (h *sniffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ctype := mime.TypeByExtension(path.Ext(r.URL.Path)); ctype == "" || ctype == "application/octet-stream" {
+		if ctype, ok := sniffFile(h.mount.localPath(r.URL.Path)); ok {
+			w.Header().Set("Content-Type", ctype)
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// sniffFile detects the content type of a regular file from its first bytes.
+func sniffFile(filename string) (string, bool) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", false
+	}
+	return http.DetectContentType(buf[:n]), true
+}
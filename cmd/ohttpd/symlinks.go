@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// checkNoSymlink returns a permission error if any component of urlPath under
+// root is a symlink. The root itself may be one. Components that do not
+// exist end the check, leaving the caller to report them as not found.
+func checkNoSymlink(root string, urlPath string) error {
+	dir := root
+	for name := range strings.SplitSeq(strings.TrimPrefix(path.Clean("/"+urlPath), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		dir = filepath.Join(dir, name)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return &fs.PathError{Op: "open", Path: urlPath, Err: fs.ErrPermission}
+		}
+	}
+	return nil
+}
+
+// noSymlinkDir is http.Dir for -follow-symlinks=false: opening any path
+// through a symlink fails with a permission error, which http.FileServer
+// answers with 403 Forbidden. That covers the index.html it opens itself.
+type noSymlinkDir string
+
+func (d noSymlinkDir) Open(name string) (http.File, error) {
+	if err := checkNoSymlink(string(d), name); err != nil {
+		return nil, err
+	}
+	return http.Dir(d).Open(name)
+}
+
+// noSymlinkHandler answers 403 for requests through a symlink before next
+// (the listing, sniffing and checksum handlers) looks at the file on disk.
+type noSymlinkHandler struct {
+	mount *Mount
+	next  http.Handler
+}
+
+func (h *noSymlinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := checkNoSymlink(h.mount.Source.Path, r.URL.Path); errors.Is(err, fs.ErrPermission) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
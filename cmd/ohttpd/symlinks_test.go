@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFollowSymlinksFalse(t *testing.T) {
+	defer func(follow bool) { followSymlinksFlag = follow }(followSymlinksFlag)
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "plain.txt"), []byte("plain"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		follow bool
+		want   map[string]int
+	}{
+		{true, map[string]int{"/plain.txt": 200, "/link.txt": 200, "/linkdir/secret.txt": 200}},
+		{false, map[string]int{"/plain.txt": 200, "/link.txt": 403, "/linkdir/secret.txt": 403, "/linkdir/": 403, "/missing": 404}},
+	} {
+		followSymlinksFlag = tc.follow
+		mounts, err := parseMounts([]string{root})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mux, err := newMux(mounts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for urlPath, want := range tc.want {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, urlPath, nil))
+			if rec.Code != want {
+				t.Errorf("-follow-symlinks=%v %s: got %d, want %d", tc.follow, urlPath, rec.Code, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// timeoutMessage is the body of the 503 sent when -handler-timeout expires.
+const timeoutMessage = "Request timed out\n"
+
+// isStreamMount reports whether path is one of the comma separated
+// -stream-mounts, which are exempt from -handler-timeout.
+func isStreamMount(path string) bool {
+	for _, mountPath := range strings.Split(streamMountsFlag, ",") {
+		if strings.TrimSpace(mountPath) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// withTimeout cuts off next with a 503 when it runs longer than timeout.
+// http.TimeoutHandler buffers the whole response until the handler returns
+// and does not support flushing, so it is skipped when timeout is zero and
+// should not be used for mounts serving large files or streams.
+func withTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.TimeoutHandler(next, timeout, timeoutMessage)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeoutCutsOffSlowHandler(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.Write([]byte("too late"))
+	})
+
+	rec := httptest.NewRecorder()
+	withTimeout(slow, 10*time.Millisecond).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != timeoutMessage {
+		t.Fatalf("got %d %q, want 503 %q", rec.Code, rec.Body.String(), timeoutMessage)
+	}
+}
+
+func TestStreamMountsSkipTimeout(t *testing.T) {
+	defer func(timeout time.Duration, streams string) {
+		handlerTimeoutFlag, streamMountsFlag = timeout, streams
+	}(handlerTimeoutFlag, streamMountsFlag)
+	handlerTimeoutFlag, streamMountsFlag = 10*time.Millisecond, "/events/, /downloads/"
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer upstream.Close()
+
+	mounts, err := parseMounts([]string{"/api/:-" + upstream.URL, "/events/:-" + upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux, err := newMux(mounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{"/api/x": http.StatusServiceUnavailable, "/events/x": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: got status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
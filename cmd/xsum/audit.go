@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// walkFiles returns the regular files under root. Symlinks are not followed.
+func walkFiles(root string) (files []string, err error) {
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return
+}
+
+// within reports whether path is root or is inside it.
+func within(path string, root string) bool {
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// doAudit rehashes every file under roots and compares it against the manifest.
+// It prints one status line per file to w: OK, CHANGED, NEW (on disk but not in the
+// manifest), UNVERIFIED (its manifest row recorded an error or no sums, so
+// there is nothing to compare), MISSING (in the manifest but not on disk) or FAILED.
+// Manifest entries outside roots are ignored. drift is true unless every file is OK.
+func doAudit(ctx context.Context, w io.Writer, manifestFile string, roots []string) (drift bool, err error) {
+	decode, ok := decoders[encodingFlag]
+	if !ok {
+		return false, fmt.Errorf("unknown encoding: %s", encodingFlag)
+	}
+	m, err := readManifest(manifestFile, decode)
+	if err != nil {
+		return false, err
+	}
+	if len(m.algorithms) == 0 {
+		return false, fmt.Errorf("%s: no sum columns", manifestFile)
+	}
+	warnMalformed(manifestFile, m)
+
+	for i, root := range roots {
+		if roots[i], err = filepath.Abs(root); err != nil {
+			return false, err
+		}
+	}
+	var files []string
+	for _, root := range roots {
+		var found []string
+		if found, err = walkFiles(root); err != nil {
+			return false, err
+		}
+		files = append(files, found...)
+	}
+	slices.Sort(files)
+	files = slices.Compact(files)
+
+	inRoots := func(filename string) bool {
+		return slices.ContainsFunc(roots, func(root string) bool { return within(filename, root) })
+	}
+	expected := make(map[string]map[string][]byte)
+	unverified := make(map[string]bool)
+	for _, entry := range m.entries {
+		if !inRoots(entry.filename) {
+			continue
+		}
+		// A row with every sum cell empty has nothing to compare against.
+		if len(entry.sums) == 0 {
+			unverified[entry.filename] = true
+		} else {
+			expected[entry.filename] = entry.sums
+		}
+	}
+	for _, filename := range m.failed {
+		if inRoots(filename) {
+			unverified[filename] = true
+		}
+	}
+	// A file listed again with sums is compared against them.
+	for filename := range expected {
+		delete(unverified, filename)
+	}
+
+	srv, err := xsum.NewServer(m.algorithms...)
+	if err != nil {
+		return false, err
+	}
+	defer srv.Close()
+
+	statuses := make(map[string]string, len(files))
+	xsum.Parallel(ctx, srv, nil, files, func(filename string, sums map[string][]byte, err error) {
+		want, ok := expected[filename]
+		switch {
+		case err != nil:
+			statuses[filename] = "FAILED " + err.Error()
+		case unverified[filename]:
+			statuses[filename] = "UNVERIFIED"
+		case !ok:
+			statuses[filename] = "NEW"
+		case !sumsMatch(want, sums):
+			statuses[filename] = "CHANGED"
+		default:
+			statuses[filename] = "OK"
+		}
+	})
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+	for filename := range expected {
+		if _, ok := statuses[filename]; !ok {
+			statuses[filename] = "MISSING"
+		}
+	}
+	for filename := range unverified {
+		if _, ok := statuses[filename]; !ok {
+			statuses[filename] = "MISSING"
+		}
+	}
+
+	problems := len(m.malformed)
+	for _, filename := range slices.Sorted(maps.Keys(statuses)) {
+		status := statuses[filename]
+		fmt.Fprintf(w, "%s: %s\n", filename, status)
+		if status == "OK" {
+			continue
+		}
+		problems++
+		if sysLog == nil {
+			continue
+		}
+		if strings.HasPrefix(status, "FAILED") {
+			sysLog.Err(fmt.Sprintf("%s: %s", filename, status))
+		} else {
+			sysLog.Warning(fmt.Sprintf("%s: %s", filename, status))
+		}
+	}
+	logSummary(problems, "audit of %d files against %s, %d drifted or failed", len(statuses), manifestFile, problems)
+	return problems > 0, nil
+}
+
+// warnMalformed prints a warning for each manifest row that could not be parsed.
+func warnMalformed(manifestFile string, m *manifest) {
+	for _, problem := range m.malformed {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", manifestFile, problem)
+		if sysLog != nil {
+			sysLog.Warning(fmt.Sprintf("%s: %s", manifestFile, problem))
+		}
+	}
+}
+
+// sumsMatch reports whether every expected sum equals the computed one.
+func sumsMatch(want map[string][]byte, got map[string][]byte) bool {
+	for algorithm, sum := range want {
+		if !bytes.Equal(sum, got[algorithm]) {
+			return false
+		}
+	}
+	return true
+}
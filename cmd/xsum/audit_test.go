@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoAudit(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	sum := func(content string) string {
+		s := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(s[:])
+	}
+	same := write("same", "same")
+	changed := write("changed", "after")
+	unreadable := write("unreadable", "now readable")
+	write("new", "new")
+	nosums := write("nosums", "nosums")
+	manifest := filepath.Join(t.TempDir(), "manifest.csv")
+	rows := "hostname,filename,size,error,sha256sum\n" +
+		fmt.Sprintf("h,%s,4,,%s\n", same, sum("same")) +
+		fmt.Sprintf("h,%s,6,,%s\n", changed, sum("before")) +
+		fmt.Sprintf("h,%s,0,permission denied,\n", unreadable) +
+		fmt.Sprintf("h,%s,6,,\n", nosums) +
+		fmt.Sprintf("h,%s,0,permission denied,\n", filepath.Join(dir, "gone")) +
+		fmt.Sprintf("h,%s,4,,%s\n", filepath.Join(dir, "removed"), sum("gone"))
+	if err := os.WriteFile(manifest, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	drift, err := doAudit(context.Background(), &out, manifest, []string{dir})
+	if err != nil || !drift {
+		t.Fatalf("expected drift, got %v, %v", drift, err)
+	}
+	// A file whose row recorded an error or has no sums has nothing to
+	// compare, but it was listed, so it is neither NEW nor silently OK.
+	want := strings.Join([]string{
+		filepath.Join(dir, "changed") + ": CHANGED",
+		filepath.Join(dir, "gone") + ": MISSING",
+		filepath.Join(dir, "new") + ": NEW",
+		filepath.Join(dir, "nosums") + ": UNVERIFIED",
+		filepath.Join(dir, "removed") + ": MISSING",
+		filepath.Join(dir, "same") + ": OK",
+		filepath.Join(dir, "unreadable") + ": UNVERIFIED",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
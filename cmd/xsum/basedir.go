@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errOutsideBase is reported for inputs outside -base-dir with -base-dir-strict.
+var errOutsideBase = errors.New("outside of base directory")
+
+// errNoGitRoot is reported by -relative-to-git-root outside a repository.
+var errNoGitRoot = errors.New("not inside a git repository")
+
+// findGitRoot walks up from dir, which must be absolute, to the nearest
+// directory containing .git. A .git file counts too, as worktrees and
+// submodules have one.
+func findGitRoot(dir string) (string, error) {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errNoGitRoot
+		}
+		dir = parent
+	}
+}
+
+// gitBaseDir returns the -base-dir for -relative-to-git-root: the root of
+// the repository enclosing the working directory, or with -git-root-fallback
+// the working directory itself when there is none.
+func gitBaseDir() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	root, err := findGitRoot(wd)
+	if errors.Is(err, errNoGitRoot) && gitFallbackFlag {
+		return wd, nil
+	}
+	return root, err
+}
+
+// relToBase returns filename relative to -base-dir, which must be absolute.
+// ok is false if filename is outside of it, in which case filename is
+// returned unchanged.
+func relToBase(filename string) (name string, ok bool) {
+	if baseDirFlag == "" {
+		return filename, true
+	}
+	rel, err := filepath.Rel(baseDirFlag, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filename, false
+	}
+	return rel, true
+}
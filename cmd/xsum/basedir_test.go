@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindGitRoot(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := findGitRoot(sub); !errors.Is(err, errNoGitRoot) && err != nil {
+		t.Fatalf("expected no repository or one above the temp dir, got %v", err)
+	}
+
+	// A .git file, as in a worktree, marks the root as well as a directory.
+	if err := os.WriteFile(filepath.Join(root, ".git"), []byte("gitdir: elsewhere\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := findGitRoot(sub)
+	if err != nil || got != root {
+		t.Fatalf("findGitRoot = %q, %v, want %q", got, err, root)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+const (
+	// benchBufferSize is the random buffer hashed repeatedly by -bench.
+	benchBufferSize = 64 << 20
+	// benchTotal is how much data each algorithm hashes.
+	benchTotal = 1 << 30
+)
+
+// doBench hashes benchTotal bytes of random data held in memory with each
+// supported algorithm and prints the throughput and implementation in use.
+// No files are read or written.
+func doBench(ctx context.Context, w io.Writer) error {
+	buf := make([]byte, benchBufferSize)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ALGORITHM\tMB/s\tIMPLEMENTATION")
+	for _, algorithm := range xsum.SupportedAlgorithms() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		srv, err := xsum.NewServer(algorithm)
+		if err != nil {
+			return err
+		}
+		h := srv.NewHash()
+		start := time.Now()
+		for range benchTotal / benchBufferSize {
+			if _, err = io.Copy(h, bytes.NewReader(buf)); err != nil {
+				break
+			}
+		}
+		h.MultiSum()
+		elapsed := time.Since(start)
+		h.Close()
+		srv.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%.0f\t%s\n", algorithm, benchTotal/1e6/elapsed.Seconds(), xsum.Implementation(algorithm))
+	}
+	return tw.Flush()
+}
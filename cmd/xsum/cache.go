@@ -2,49 +2,84 @@ package main
 
 import (
 	"encoding/binary"
+	"errors"
+	"io/fs"
 	"os"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ophymx/utils/attrutil"
 	"github.com/ophymx/utils/xsum"
 )
 
-const xsumNS = "user.xsum"
+// defaultCacheNS is the attribute namespace used unless -cache-ns is given.
+const defaultCacheNS = "user.xsum"
 
 type xattrCache struct {
 	attrs attrutil.Attr
+	// readOnly skips every write, including the cleanup of stale entries.
+	readOnly bool
 }
 
-var _ xsum.Cache = (*xattrCache)(nil)
+var _ xsum.StatCache = (*xattrCache)(nil)
 
-func newXattrCache() *xattrCache {
-	return &xattrCache{attrutil.Xattr().NS(xsumNS)}
+// newXattrCache returns a cache storing sums under the attribute namespace ns.
+func newXattrCache(ns string) *xattrCache {
+	return &xattrCache{attrs: attrutil.Xattr().NS(ns)}
 }
 
+// readOnlyCache serves sums from a cache without ever writing to it.
+type readOnlyCache struct {
+	xsum.Cache
+}
+
+// Set implements xsum.Cache and does nothing.
+func (readOnlyCache) Set(string, map[string][]byte) error { return nil }
+
+// mtimeKey holds the modification time, in nanoseconds, that a file had
+// before the cached sums were read from it. Caches written by older versions
+// have a "time" key with the wall clock time of the write instead; they are
+// treated as misses and the key is dropped by the next Set.
+const (
+	mtimeKey  = "mtime"
+	legacyKey = "time"
+)
+
 func timeToBytes(t time.Time) []byte {
-	return binary.LittleEndian.AppendUint64(nil, uint64(t.Unix()))
+	return binary.LittleEndian.AppendUint64(nil, uint64(t.UnixNano()))
 }
 
-func timeFromBytes(b []byte) time.Time {
-	return time.Unix(int64(binary.LittleEndian.Uint64(b)), 0)
+func timeFromBytes(b []byte) (time.Time, bool) {
+	if len(b) != 8 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.LittleEndian.Uint64(b))), true
 }
 
 // Get returns the cached sums for the given filename.
-// If the file has been modified since the last time the sums were cached, nil is returned.
+// If the file has no cache entry or its modification time differs from the
+// one it had when the cached sums were read, nil is returned. An error means
+// the cache could not be read, e.g. because the filesystem does not support
+// extended attributes.
 func (c *xattrCache) Get(filename string) (map[string][]byte, error) {
 	info, err := os.Stat(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := c.attrs.Get(filename, "time")
+	b, err := c.attrs.Get(filename, mtimeKey)
+	if attrutil.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	timestamp := timeFromBytes(b)
 
-	if info.ModTime().After(timestamp) {
-		c.attrs.DeleteNS(filename, "") // ignore error, this is just cleanup
+	if mtime, ok := timeFromBytes(b); !ok || !info.ModTime().Equal(mtime) {
+		if !c.readOnly {
+			c.attrs.DeleteNS(filename, "") // ignore error, this is just cleanup
+		}
 		return nil, nil
 	}
 
@@ -54,7 +89,8 @@ func (c *xattrCache) Get(filename string) (map[string][]byte, error) {
 	}
 	sums := make(map[string][]byte)
 	for _, key := range keys {
-		if key == "time" {
+		// Nested keys belong to another cache namespace, e.g. user.xsum.strict.
+		if key == mtimeKey || key == legacyKey || strings.Contains(key, ".") {
 			continue
 		}
 		if b, err := c.attrs.Get(filename, key); err == nil {
@@ -64,12 +100,47 @@ func (c *xattrCache) Get(filename string) (map[string][]byte, error) {
 	return sums, nil
 }
 
-// Set sets the cached sums for the given filename.
+// Set sets the cached sums for the given filename with its current
+// modification time. Parallel calls SetStat instead, with the time from
+// before the file was read.
 func (c *xattrCache) Set(filename string, sums map[string][]byte) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return c.SetStat(filename, info, sums)
+}
+
+// SetStat implements xsum.StatCache, replacing the previous entry: sums
+// cached for algorithms not in sums are removed, so they cannot pass as
+// fresh under the new modification time. Files on a read-only filesystem are
+// skipped without an error, so read-only data is still served from whatever
+// cache entries it was archived with.
+func (c *xattrCache) SetStat(filename string, info fs.FileInfo, sums map[string][]byte) error {
+	if c.readOnly {
+		return nil
+	}
+	keys, err := c.attrs.List(filename)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		// Nested keys belong to another cache namespace, e.g. user.xsum.strict.
+		if _, ok := sums[key]; ok || key == mtimeKey || strings.Contains(key, ".") {
+			continue
+		}
+		if err := c.attrs.Delete(filename, key); errors.Is(err, syscall.EROFS) {
+			return nil
+		} else if err != nil && !attrutil.IsNotExist(err) {
+			return err
+		}
+	}
 	for algorithm, sum := range sums {
-		if err := c.attrs.Set(filename, algorithm, sum); err != nil {
+		if err := c.attrs.Set(filename, algorithm, sum); errors.Is(err, syscall.EROFS) {
+			return nil
+		} else if err != nil {
 			return err
 		}
 	}
-	return c.attrs.Set(filename, "time", timeToBytes(time.Now()))
+	return c.attrs.Set(filename, mtimeKey, timeToBytes(info.ModTime()))
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ophymx/utils/attrutil"
+)
+
+func TestXattrCacheReadOnly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filename, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writable := newXattrCache("user.xsum-test")
+	if err := writable.Set(filename, map[string][]byte{"sha256": {1}}); attrutil.IsNotSupported(err) {
+		t.Skipf("extended attributes not supported: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := newXattrCache("user.xsum-test")
+	readOnly.readOnly = true
+	if sums, err := readOnly.Get(filename); err != nil || string(sums["sha256"]) != "\x01" {
+		t.Fatalf("expected the cached sum to be read, got %v, %v", sums, err)
+	}
+	if err := readOnly.Set(filename, map[string][]byte{"sha256": {2}}); err != nil {
+		t.Fatal(err)
+	}
+	if sums, _ := writable.Get(filename); string(sums["sha256"]) != "\x01" {
+		t.Fatalf("read-only Set wrote to the cache: %v", sums)
+	}
+
+	// A stale entry is ignored but, unlike a writable cache, left in place.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filename, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if sums, err := readOnly.Get(filename); err != nil || sums != nil {
+		t.Fatalf("expected a miss for a modified file, got %v, %v", sums, err)
+	}
+	if _, err := writable.attrs.Get(filename, "sha256"); err != nil {
+		t.Fatalf("read-only Get removed the stale entry: %v", err)
+	}
+}
+
+func TestXattrCacheSetRemovesStaleAlgorithms(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filename, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filename, past, past); err != nil {
+		t.Fatal(err)
+	}
+	cache := newXattrCache("user.xsum-test")
+	if err := cache.Set(filename, map[string][]byte{"md5": {1}, "sha256": {2}}); attrutil.IsNotSupported(err) {
+		t.Skipf("extended attributes not supported: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(filename, map[string][]byte{"sha256": {3}}); err != nil {
+		t.Fatal(err)
+	}
+	sums, err := cache.Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 1 || string(sums["sha256"]) != "\x03" {
+		t.Fatalf("expected only the new sha256 to be cached, got %v", sums)
+	}
+}
+
+func TestXattrCacheStaleAfterChangeDuringHash(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filename, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newXattrCache("user.xsum-test")
+	if err := cache.SetStat(filename, before, map[string][]byte{"sha256": {1}}); attrutil.IsNotSupported(err) {
+		t.Skipf("extended attributes not supported: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if sums, err := cache.Get(filename); err != nil || sums == nil {
+		t.Fatalf("expected a hit for an unchanged file, got %v, %v", sums, err)
+	}
+
+	// Written while it was being hashed: the stored time is the one from
+	// before the read, so the entry is stale even though it was just written.
+	during := before.ModTime().Add(time.Millisecond)
+	if err := os.Chtimes(filename, during, during); err != nil {
+		t.Fatal(err)
+	}
+	if sums, err := cache.Get(filename); err != nil || sums != nil {
+		t.Fatalf("expected a miss for a file modified during hashing, got %v, %v", sums, err)
+	}
+}
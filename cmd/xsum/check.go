@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// checkAlgorithms returns the manifest algorithms to verify: those requested
+// with -a, or else every one xsum supports. Manifest columns for algorithms
+// that are not verified are ignored.
+func checkAlgorithms(m *manifest) ([]string, error) {
+	var algorithms []string
+	requested := strings.Split(algorithmFlag, ",")
+	for _, algorithm := range m.algorithms {
+		if flagSet("a") && !slices.Contains(requested, algorithm) {
+			continue
+		}
+		if !slices.Contains(xsum.SupportedAlgorithms(), algorithm) {
+			fmt.Fprintf(os.Stderr, "warning: ignoring unsupported %s sums\n", algorithm)
+			continue
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	if len(algorithms) == 0 {
+		return nil, errors.New("no sums to check")
+	}
+	return algorithms, nil
+}
+
+// doCheck rehashes the files listed in a manifest and prints OK, FAILED or
+// MISSING for each, like sha256sum -c, followed by a count of each status.
+// Manifest rows that cannot be parsed are reported and counted as FAILED.
+// With -verify-sig the manifest is trusted only if its minisign signature
+// verifies.
+// failed is true unless every file is OK.
+func doCheck(ctx context.Context, manifestFile string) (failed bool, err error) {
+	decode, ok := decoders[encodingFlag]
+	if !ok {
+		return false, fmt.Errorf("unknown encoding: %s", encodingFlag)
+	}
+	var m *manifest
+	if verifySigFlag != "" {
+		var key *minisignKey
+		if key, err = parseMinisignKey(verifySigFlag); err != nil {
+			return false, err
+		}
+		m, err = readSignedManifest(manifestFile, key, decode)
+	} else {
+		m, err = readManifest(manifestFile, decode)
+	}
+	if err != nil {
+		return false, err
+	}
+	algorithms, err := checkAlgorithms(m)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	warnWeak(os.Stderr, algorithms)
+
+	expected := make(map[string]map[string][]byte, len(m.entries))
+	var files []string
+	for _, entry := range m.entries {
+		if _, ok := expected[entry.filename]; !ok {
+			files = append(files, entry.filename)
+		}
+		expected[entry.filename] = entry.sums
+	}
+
+	srv, err := xsum.NewServer(algorithms...)
+	if err != nil {
+		return false, err
+	}
+	defer srv.Close()
+
+	statuses := make(map[string]string, len(files))
+	xsum.Parallel(ctx, srv, nil, files, func(filename string, sums map[string][]byte, err error) {
+		want := make(map[string][]byte, len(algorithms))
+		for _, algorithm := range algorithms {
+			if sum, ok := expected[filename][algorithm]; ok {
+				want[algorithm] = sum
+			}
+		}
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			statuses[filename] = "MISSING"
+		case err != nil:
+			statuses[filename] = "FAILED " + err.Error()
+		case len(want) == 0:
+			statuses[filename] = "FAILED no " + strings.Join(algorithms, " or ") + " sum in manifest"
+		case !sumsMatch(want, sums):
+			statuses[filename] = "FAILED"
+		default:
+			statuses[filename] = "OK"
+		}
+	})
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+
+	counts := make(map[string]int)
+	for _, filename := range files {
+		status := statuses[filename]
+		fmt.Printf("%s: %s\n", filename, status)
+		status, _, _ = strings.Cut(status, " ")
+		counts[status]++
+		if status != "OK" {
+			logFailure(filename, errors.New(strings.ToLower(statuses[filename])))
+		}
+	}
+	for _, problem := range m.malformed {
+		fmt.Printf("%s: FAILED malformed %s\n", manifestFile, problem)
+		counts["FAILED"]++
+	}
+
+	var summary []string
+	for _, status := range []string{"OK", "FAILED", "MISSING"} {
+		if counts[status] > 0 || status == "OK" {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Println(strings.Join(summary, ", "))
+	problems := counts["FAILED"] + counts["MISSING"]
+	logSummary(problems, "check of %s: %s", manifestFile, strings.Join(summary, ", "))
+	return problems > 0, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoCheck(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	if err := os.WriteFile(good, []byte("good"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("good"))
+	manifest := filepath.Join(dir, "manifest.csv")
+	write := func(rows string) {
+		t.Helper()
+		content := "hostname,filename,size,error,sha256sum,crc99sum\n" + rows
+		if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The unsupported crc99 column is ignored.
+	write(fmt.Sprintf("h,%s,4,,%s,ff\n", good, hex.EncodeToString(sum[:])))
+	if failed, err := doCheck(context.Background(), manifest); err != nil || failed {
+		t.Fatalf("expected a matching file to pass, got failed=%v, err=%v", failed, err)
+	}
+
+	for name, rows := range map[string]string{
+		"mismatch":  fmt.Sprintf("h,%s,4,,%s,ff\n", good, hex.EncodeToString(make([]byte, 32))),
+		"missing":   fmt.Sprintf("h,%s,4,,%s,ff\n", filepath.Join(dir, "gone"), hex.EncodeToString(sum[:])),
+		"truncated": fmt.Sprintf("h,%s,4,,%s,ff\nh,%s,4\n", good, hex.EncodeToString(sum[:]), good),
+	} {
+		write(rows)
+		if failed, err := doCheck(context.Background(), manifest); err != nil || !failed {
+			t.Errorf("%s: expected the check to fail, got failed=%v, err=%v", name, failed, err)
+		}
+	}
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{`weird, "name".txt`, "new\nline", "colon: and  spaces", " leading"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []string{"csv", "json"} {
+		manifest := filepath.Join(dir, "manifest."+format)
+		f, err := os.Create(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := writers[format](f, writerConfig{algorithms: []string{"sha256"}, encode: encoders["hex"]})
+		for _, name := range names {
+			sum := sha256.Sum256([]byte(name))
+			if err := w.Write("h", filepath.Join(dir, name), int64(len(name)), map[string][]byte{"sha256": sum[:]}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if failed, err := doCheck(context.Background(), manifest); err != nil || failed {
+			t.Errorf("%s: expected every file to pass, got failed=%v, err=%v", format, failed, err)
+		}
+	}
+
+	// Columns are found by header, so a hand-edited manifest may reorder them.
+	manifest := filepath.Join(dir, "reordered.csv")
+	sum := sha256.Sum256([]byte(names[0]))
+	content := fmt.Sprintf("sha256sum,filename\n%s,\"%s\"\n", hex.EncodeToString(sum[:]), strings.ReplaceAll(filepath.Join(dir, names[0]), `"`, `""`))
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if failed, err := doCheck(context.Background(), manifest); err != nil || failed {
+		t.Errorf("reordered: expected the file to pass, got failed=%v, err=%v", failed, err)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// chunkWindow is the number of bytes the rolling hash covers.
+const chunkWindow = 64
+
+// buzTable maps each byte to a random 32-bit value for the buzhash. It is
+// generated from a fixed seed so chunk boundaries are the same on every run
+// and every machine.
+var buzTable = func() (table [256]uint32) {
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = uint32(z ^ z>>31)
+	}
+	return
+}()
+
+// maxChunkAvg is the largest average chunk size; a chunk is buffered whole,
+// so it bounds memory to four times this.
+const maxChunkAvg = 16 << 20
+
+// chunker splits a stream into content-defined chunks with a buzhash over
+// the last chunkWindow bytes. A boundary is placed where the low bits of the
+// hash are zero, so inserting or removing bytes only moves the boundaries
+// near the edit. Chunks are at least a quarter and at most four times the
+// average size.
+type chunker struct {
+	r        *bufio.Reader
+	mask     uint32
+	min, max int
+	buf      []byte
+}
+
+// newChunker returns a chunker for r with an average chunk size of avg
+// bytes, rounded up to a power of two.
+func newChunker(r io.Reader, avg int) *chunker {
+	avg = 1 << bits.Len(uint(avg-1))
+	return &chunker{
+		r:    bufio.NewReaderSize(r, xsum.DefaultBufferSize),
+		mask: uint32(avg - 1),
+		min:  avg / 4,
+		max:  avg * 4,
+		buf:  make([]byte, 0, avg*4),
+	}
+}
+
+// next returns the next chunk, valid until the following call, or io.EOF
+// after the last one. It scans whatever the reader has buffered at a time
+// and consumes only up to the boundary it finds.
+func (c *chunker) next() ([]byte, error) {
+	c.buf = c.buf[:0]
+	var h uint32
+	for len(c.buf) < c.max {
+		peeked, err := c.r.Peek(min(max(c.r.Buffered(), 1), c.max-len(c.buf)))
+		if len(peeked) == 0 {
+			if err == io.EOF {
+				if len(c.buf) == 0 {
+					return nil, io.EOF
+				}
+				return c.buf, nil
+			}
+			return nil, err
+		}
+		start := len(c.buf)
+		c.buf = append(c.buf, peeked...)
+		for i := start; i < len(c.buf); i++ {
+			h = bits.RotateLeft32(h, 1) ^ buzTable[c.buf[i]]
+			if i >= chunkWindow {
+				h ^= bits.RotateLeft32(buzTable[c.buf[i-chunkWindow]], chunkWindow)
+			}
+			if i+1 >= c.min && h&c.mask == 0 {
+				c.r.Discard(i + 1 - start)
+				c.buf = c.buf[:i+1]
+				return c.buf, nil
+			}
+		}
+		c.r.Discard(len(c.buf) - start)
+	}
+	return c.buf, nil
+}
+
+// chunkRowWriter writes one row per chunk, in CSV or JSON lines, with the
+// chunk's offset and length in place of the file size.
+type chunkRowWriter struct {
+	csv        *csv.Writer
+	json       *json.Encoder
+	algorithms []string
+	encode     sumEncoder
+}
+
+func newChunkRowWriter(w io.Writer, format string, cfg writerConfig) (*chunkRowWriter, error) {
+	cw := &chunkRowWriter{algorithms: cfg.algorithms, encode: cfg.encode}
+	switch format {
+	case "csv":
+		cw.csv = csv.NewWriter(w)
+		headers := []string{"hostname", "filename", "offset", "length", "error"}
+		for _, algorithm := range cfg.algorithms {
+			headers = append(headers, algorithm+"sum")
+		}
+		if err := cw.csv.Write(headers); err != nil {
+			return nil, err
+		}
+	case "json":
+		cw.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("-chunk supports csv and json output, not %s", format)
+	}
+	return cw, nil
+}
+
+func (w *chunkRowWriter) Write(hostname string, filename string, offset int64, length int, sums map[string][]byte, err error) error {
+	if w.json != nil {
+		data := map[string]any{
+			"hostname": hostname,
+			"filename": filename,
+			"offset":   offset,
+			"length":   length,
+		}
+		if err != nil {
+			data["error"] = err.Error()
+		} else {
+			for algorithm, sum := range sums {
+				data[algorithm+"sum"] = w.encode(sum)
+			}
+		}
+		return w.json.Encode(data)
+	}
+	data := []string{hostname, filename, strconv.FormatInt(offset, 10), strconv.Itoa(length), ""}
+	if err != nil {
+		data[4] = err.Error()
+	}
+	for _, algorithm := range w.algorithms {
+		if sum := sums[algorithm]; sum != nil {
+			data = append(data, w.encode(sum))
+		} else {
+			data = append(data, "")
+		}
+	}
+	if err := w.csv.Write(data); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *chunkRowWriter) Close() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// doChunk splits each file into content-defined chunks of about avg bytes and
+// writes a row with the digests of every chunk. A file that cannot be read
+// gets a single error row.
+func doChunk(ctx context.Context, filenames []string, algorithms []string, avg int) (err error) {
+	encode, ok := encoders[encodingFlag]
+	if !ok {
+		return fmt.Errorf("unknown encoding: %s", encodingFlag)
+	}
+	srv, err := xsum.NewServer(algorithms...)
+	if err != nil {
+		return
+	}
+	defer srv.Close()
+	if shortFlag != 0 {
+		if encodingFlag != "hex" {
+			return fmt.Errorf("-short needs hex encoding, not %s", encodingFlag)
+		}
+		if encode, err = shortEncoder(shortFlag, srv); err != nil {
+			return
+		}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return
+	}
+
+	out, err := openOutput(outFileFlag, compressFlag)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cErr := out.Close(); err == nil {
+			err = cErr
+		}
+	}()
+	writer, err := newChunkRowWriter(out, outputFlag, writerConfig{algorithms: srv.Algorithms(), encode: encode})
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cErr := writer.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	h := srv.NewHash()
+	defer h.Close()
+	failed := 0
+	for _, filename := range filenames {
+		if ctx.Err() != nil {
+			break
+		}
+		outName := filename
+		if abs, aErr := filepath.Abs(filename); aErr == nil {
+			outName, _ = relToBase(abs)
+		}
+		var wErr error
+		cErr := chunkFile(ctx, filename, avg, h, func(offset int64, length int, sums map[string][]byte) error {
+			wErr = writer.Write(hostname, outName, offset, length, sums, nil)
+			return wErr
+		})
+		if wErr != nil {
+			return wErr
+		}
+		if cErr != nil {
+			failed++
+			if err = writer.Write(hostname, outName, 0, 0, nil, cErr); err != nil {
+				return
+			}
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d files failed", failed, len(filenames))
+	}
+	return
+}
+
+// chunkFile hashes each chunk of filename with h and passes it to emit.
+func chunkFile(ctx context.Context, filename string, avg int, h xsum.Hasher, emit func(offset int64, length int, sums map[string][]byte) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c := newChunker(f, avg)
+	var offset int64
+	for ctx.Err() == nil {
+		chunk, err := c.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		h.Reset()
+		h.Write(chunk)
+		if err = emit(offset, len(chunk), h.MultiSum()); err != nil {
+			return err
+		}
+		offset += int64(len(chunk))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+func chunks(t *testing.T, data []byte, avg int) []string {
+	t.Helper()
+	var out []string
+	c := newChunker(bytes.NewReader(data), avg)
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			return out
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) > 4*avg {
+			t.Fatalf("chunk of %d bytes exceeds the maximum of %d", len(chunk), 4*avg)
+		}
+		out = append(out, string(chunk))
+	}
+}
+
+func TestChunkerResynchronizes(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.NewChaCha8([32]byte{}).Read(data)
+	original := chunks(t, data, 16*1024)
+	if len(original) < 16 {
+		t.Fatalf("expected many chunks of 1 MiB, got %d", len(original))
+	}
+	var joined bytes.Buffer
+	for _, chunk := range original {
+		joined.WriteString(chunk)
+	}
+	if !bytes.Equal(joined.Bytes(), data) {
+		t.Fatal("chunks do not reassemble to the input")
+	}
+
+	shifted := chunks(t, append([]byte("inserted"), data...), 16*1024)
+	seen := make(map[string]bool, len(original))
+	for _, chunk := range original {
+		seen[chunk] = true
+	}
+	shared := 0
+	for _, chunk := range shifted {
+		if seen[chunk] {
+			shared++
+		}
+	}
+	if shared < len(original)-2 {
+		t.Fatalf("only %d of %d chunks survived an insertion at the start", shared, len(original))
+	}
+}
+
+func TestChunkerShortReads(t *testing.T) {
+	data := make([]byte, 256*1024)
+	rand.NewChaCha8([32]byte{1}).Read(data)
+	want := chunks(t, data, 1024)
+	// Boundaries do not depend on how much each read returns.
+	c := newChunker(iotest.OneByteReader(bytes.NewReader(data)), 1024)
+	var got []string
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(chunk))
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %d chunks with one-byte reads, want the same %d", len(got), len(want))
+	}
+}
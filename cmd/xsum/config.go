@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// configFile is read from the working directory for team-wide defaults.
+const configFile = ".xsum"
+
+// configKeys maps the keys accepted in the config file to flag names.
+var configKeys = map[string]string{
+	"algorithms": "a",
+	"format":     "f",
+	"encoding":   "encoding",
+	"cache":      "c",
+	"cache-ns":   "cache-ns",
+}
+
+// loadDefaults replaces flag defaults before the command line is parsed, so the
+// precedence is: flags, then the XSUM_ALGORITHMS environment variable, then
+// the config file, then the built-in defaults.
+//
+// The config file holds "key = value" lines with the keys in configKeys;
+// blank lines and lines starting with '#' are ignored. A missing file is not
+// an error.
+func loadDefaults(flags *flag.FlagSet, filename string, getenv func(string) string) error {
+	f, err := os.Open(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(text, "=")
+			name, known := configKeys[strings.TrimSpace(key)]
+			if !ok || !known {
+				return fmt.Errorf("%s:%d: expected one of algorithms, format, encoding, cache or cache-ns = value", filename, line)
+			}
+			if err = setDefault(flags, name, strings.TrimSpace(value)); err != nil {
+				return fmt.Errorf("%s:%d: %w", filename, line, err)
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return err
+		}
+	}
+	if algorithms := getenv("XSUM_ALGORITHMS"); algorithms != "" {
+		if err = setDefault(flags, "a", algorithms); err != nil {
+			return fmt.Errorf("XSUM_ALGORITHMS: %w", err)
+		}
+	}
+	return nil
+}
+
+// setDefault sets a flag's value and default without marking it as given on
+// the command line.
+func setDefault(flags *flag.FlagSet, name, value string) error {
+	f := flags.Lookup(name)
+	if err := f.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	f.DefValue = value
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newConfigFlags() (*flag.FlagSet, *string, *string, *bool) {
+	flags := flag.NewFlagSet("xsum", flag.ContinueOnError)
+	algorithms := flags.String("a", "sha256,md5", "")
+	format := flags.String("f", "csv", "")
+	cache := flags.Bool("c", true, "")
+	flags.String("encoding", "hex", "")
+	flags.String("cache-ns", defaultCacheNS, "")
+	return flags, algorithms, format, cache
+}
+
+func TestLoadDefaultsPrecedence(t *testing.T) {
+	config := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(config, []byte("# team policy\nalgorithms = sha512\nformat = json\ncache = false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	flags, algorithms, format, cache := newConfigFlags()
+	if err := loadDefaults(flags, config, getenv); err != nil {
+		t.Fatal(err)
+	}
+	if *algorithms != "sha512" || *format != "json" || *cache {
+		t.Fatalf("config not applied: a=%s f=%s c=%v", *algorithms, *format, *cache)
+	}
+
+	env["XSUM_ALGORITHMS"] = "sha1"
+	flags, algorithms, format, _ = newConfigFlags()
+	if err := loadDefaults(flags, config, getenv); err != nil {
+		t.Fatal(err)
+	}
+	if *algorithms != "sha1" || *format != "json" {
+		t.Fatalf("env should override config: a=%s f=%s", *algorithms, *format)
+	}
+
+	if err := flags.Parse([]string{"-a", "md5"}); err != nil {
+		t.Fatal(err)
+	}
+	if *algorithms != "md5" {
+		t.Fatalf("flag should override env: a=%s", *algorithms)
+	}
+}
+
+func TestLoadDefaultsMissingAndInvalid(t *testing.T) {
+	dir := t.TempDir()
+	flags, algorithms, _, _ := newConfigFlags()
+	if err := loadDefaults(flags, filepath.Join(dir, configFile), func(string) string { return "" }); err != nil {
+		t.Fatalf("missing config should be ignored: %v", err)
+	}
+	if *algorithms != "sha256,md5" {
+		t.Fatalf("built-in default changed: %s", *algorithms)
+	}
+
+	config := filepath.Join(dir, configFile)
+	if err := os.WriteFile(config, []byte("colour = blue\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDefaults(flags, config, func(string) string { return "" }); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestWarnWeakFromAnySource(t *testing.T) {
+	defer func(allow bool) { allowWeakFlag = allow }(allowWeakFlag)
+	allowWeakFlag = false
+	// warnWeak does not look at how the algorithms were chosen, so a .xsum
+	// or XSUM_ALGORITHMS choice is warned about like -a.
+	for _, tc := range []struct {
+		algorithms string
+		warn       bool
+	}{
+		{"sha256,md5", false},
+		{"md5,sha256", false},
+		{"md5", true},
+		{"sha256,sha1", true},
+		{"sha512", false},
+	} {
+		var out strings.Builder
+		warnWeak(&out, strings.Split(tc.algorithms, ","))
+		if got := out.Len() > 0; got != tc.warn {
+			t.Errorf("%s: warned %v, want %v (%q)", tc.algorithms, got, tc.warn, out.String())
+		}
+	}
+}
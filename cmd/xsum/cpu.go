@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/cpuid/v2"
+	"github.com/ophymx/utils/xsum"
+)
+
+// simdFeatures are the CPU features that decide which hash implementations are used.
+var simdFeatures = []cpuid.FeatureID{
+	cpuid.SSSE3, cpuid.SSE4, cpuid.AVX2,
+	cpuid.AVX512F, cpuid.AVX512DQ, cpuid.AVX512BW, cpuid.AVX512VL,
+	cpuid.SHA, cpuid.SHA2,
+}
+
+// reportCPU writes the relevant CPU features and the implementation chosen
+// for each algorithm, for -v.
+func reportCPU(w io.Writer, algorithms []string) {
+	var features []string
+	for _, feature := range simdFeatures {
+		if cpuid.CPU.Supports(feature) {
+			features = append(features, feature.String())
+		}
+	}
+	if len(features) == 0 {
+		features = []string{"none"}
+	}
+	fmt.Fprintf(w, "cpu: %s (%s)\n", cpuid.CPU.BrandName, strings.Join(features, " "))
+	for _, algorithm := range algorithms {
+		fmt.Fprintf(w, "%s: %s\n", algorithm, xsum.Implementation(algorithm))
+	}
+}
@@ -8,20 +8,34 @@ import (
 )
 
 type csvWriter struct {
+	out          io.Writer
+	envelope     bool
 	writer       *csv.Writer
 	wroteHeaders bool
 	algorithms   []string
+	encode       sumEncoder
+	humanSize    bool
+	summary      *outputSummary
 }
 
 // Close implements xsumWriter.
 func (w *csvWriter) Close() error {
 	w.writer.Flush()
-	return w.writer.Error()
+	if err := w.writer.Error(); err != nil || w.summary == nil {
+		return err
+	}
+	_, err := io.WriteString(w.out, w.summary.csvLine())
+	return err
 }
 
 // Write implements xsumWriter.
 func (w *csvWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, sErr error) error {
 	if !w.wroteHeaders {
+		if w.envelope {
+			if _, err := fmt.Fprintf(w.out, "%s %d\n", csvVersionPrefix, schemaVersion); err != nil {
+				return err
+			}
+		}
 		headers := []string{"hostname", "filename", "size", "error"}
 		for _, algorithm := range w.algorithms {
 			headers = append(headers, algorithm+"sum")
@@ -32,7 +46,14 @@ func (w *csvWriter) Write(hostname string, filename string, size int64, sums map
 		w.wroteHeaders = true
 	}
 
-	data := []string{hostname, filename, strconv.FormatInt(size, 10)}
+	if w.summary != nil {
+		w.summary.add(size)
+	}
+	sizeStr := strconv.FormatInt(size, 10)
+	if w.humanSize {
+		sizeStr = formatSize(size)
+	}
+	data := []string{hostname, filename, sizeStr}
 	if sErr != nil {
 		data = append(data, sErr.Error())
 	} else {
@@ -44,7 +65,7 @@ func (w *csvWriter) Write(hostname string, filename string, size int64, sums map
 		if sum == nil {
 			data = append(data, "")
 		} else {
-			data = append(data, fmt.Sprintf("%x", sum))
+			data = append(data, w.encode(sum))
 		}
 	}
 
@@ -55,8 +76,20 @@ func (w *csvWriter) Write(hostname string, filename string, size int64, sums map
 	return w.writer.Error()
 }
 
-func newCsvWriter(w io.Writer, algorithms []string) *csvWriter {
-	return &csvWriter{csv.NewWriter(w), false, algorithms}
+func newCsvWriter(w io.Writer, cfg writerConfig) *csvWriter {
+	var summary *outputSummary
+	if cfg.summary {
+		summary = newOutputSummary()
+	}
+	return &csvWriter{
+		out:        w,
+		envelope:   cfg.envelope,
+		writer:     csv.NewWriter(w),
+		algorithms: cfg.algorithms,
+		encode:     cfg.encode,
+		humanSize:  cfg.humanSize,
+		summary:    summary,
+	}
 }
 
 var _ xsumWriter = new(csvWriter)
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// diffAlgorithms returns the algorithms to compare two manifests by: sha256
+// when both have it, otherwise every algorithm they share.
+func diffAlgorithms(a, b []string) ([]string, error) {
+	if slices.Contains(a, "sha256") && slices.Contains(b, "sha256") {
+		return []string{"sha256"}, nil
+	}
+	var common []string
+	for _, algorithm := range a {
+		if slices.Contains(b, algorithm) {
+			common = append(common, algorithm)
+		}
+	}
+	if len(common) == 0 {
+		return nil, fmt.Errorf("no sum column in common (%v and %v)", a, b)
+	}
+	return common, nil
+}
+
+// sameSums reports whether a and b agree on every algorithm.
+func sameSums(algorithms []string, a, b map[string][]byte) bool {
+	for _, algorithm := range algorithms {
+		if !bytes.Equal(a[algorithm], b[algorithm]) {
+			return false
+		}
+	}
+	return true
+}
+
+// doDiff compares two manifests without reading any of the files they list
+// and prints a line for each file that was ADDED, REMOVED or CHANGED between
+// them, or with -v every file including those that are OK. Filenames are
+// matched as they resolve against -base-dir or the working directory, so
+// two manifests written with the same -base-dir compare cleanly.
+// differ is true if any file differs or either manifest has malformed rows.
+func doDiff(w io.Writer, oldFile, newFile string) (differ bool, err error) {
+	decode, ok := decoders[encodingFlag]
+	if !ok {
+		return false, fmt.Errorf("unknown encoding: %s", encodingFlag)
+	}
+	oldManifest, err := readManifest(oldFile, decode)
+	if err != nil {
+		return false, err
+	}
+	newManifest, err := readManifest(newFile, decode)
+	if err != nil {
+		return false, err
+	}
+	warnMalformed(oldFile, oldManifest)
+	warnMalformed(newFile, newManifest)
+	algorithms, err := diffAlgorithms(oldManifest.algorithms, newManifest.algorithms)
+	if err != nil {
+		return false, fmt.Errorf("%s and %s: %w", oldFile, newFile, err)
+	}
+
+	entries := func(m *manifest) map[string]map[string][]byte {
+		sums := make(map[string]map[string][]byte, len(m.entries))
+		for _, entry := range m.entries {
+			sums[entry.filename] = entry.sums
+		}
+		return sums
+	}
+	before, after := entries(oldManifest), entries(newManifest)
+	names := slices.Sorted(maps.Keys(before))
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	problems := len(oldManifest.malformed) + len(newManifest.malformed)
+	for _, name := range names {
+		oldSums, inOld := before[name]
+		newSums, inNew := after[name]
+		status := "OK"
+		switch {
+		case !inOld:
+			status = "ADDED"
+		case !inNew:
+			status = "REMOVED"
+		case !sameSums(algorithms, oldSums, newSums):
+			status = "CHANGED"
+		}
+		if status != "OK" {
+			problems++
+		} else if !verboseFlag {
+			continue
+		}
+		outName, _ := relToBase(name)
+		fmt.Fprintf(w, "%s: %s\n", outName, status)
+	}
+	return problems > 0, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoDiff(t *testing.T) {
+	defer func(base string) { baseDirFlag = base }(baseDirFlag)
+	dir := t.TempDir()
+	baseDirFlag = dir
+
+	oldFile := filepath.Join(dir, "old.csv")
+	newFile := filepath.Join(dir, "new.json")
+	oldCSV := "hostname,filename,size,error,md5sum,sha256sum\n" +
+		"h,same,1,,00,aa\n" +
+		"h,changed,1,,00,bb\n" +
+		"h,removed,1,,00,cc\n"
+	// Only sha256 is compared when both manifests have it, so md5 may differ.
+	newJSON := `{"version":1,"files":[
+{"filename":"same","sha256sum":"aa"},
+{"filename":"changed","sha256sum":"dd"},
+{"filename":"added","sha256sum":"ee"}
+]}`
+	if err := os.WriteFile(oldFile, []byte(oldCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newFile, []byte(newJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	differ, err := doDiff(&out, oldFile, newFile)
+	if err != nil || !differ {
+		t.Fatalf("expected a difference, got differ=%v, err=%v", differ, err)
+	}
+	want := "added: ADDED\nchanged: CHANGED\nremoved: REMOVED\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if differ, err = doDiff(&out, oldFile, oldFile); err != nil || differ || out.Len() != 0 {
+		t.Fatalf("expected a manifest to match itself, got differ=%v, err=%v, output %q", differ, err, out.String())
+	}
+}
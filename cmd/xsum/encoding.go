@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// sumEncoder renders a digest as text for the output writers.
+type sumEncoder func(sum []byte) string
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// encoders maps -encoding names to their sumEncoder.
+// base32 is unpadded lowercase so it is safe in filenames and case-insensitive contexts.
+var encoders = map[string]sumEncoder{
+	"hex":       hex.EncodeToString,
+	"base64":    base64.StdEncoding.EncodeToString,
+	"base64url": base64.RawURLEncoding.EncodeToString,
+	"base32": func(sum []byte) string {
+		return strings.ToLower(base32NoPad.EncodeToString(sum))
+	},
+}
+
+// shortEncoder returns the -short encoder: the first n hex characters of each
+// digest. n must be even and no longer than the shortest digest srv computes.
+func shortEncoder(n int, srv xsum.Server) (sumEncoder, error) {
+	if n <= 0 || n%2 != 0 {
+		return nil, fmt.Errorf("-short must be a positive even number of hex characters, not %d", n)
+	}
+	h := srv.NewHash()
+	defer h.Close()
+	for algorithm, sum := range h.MultiSum() {
+		if n > hex.EncodedLen(len(sum)) {
+			return nil, fmt.Errorf("-short %d is longer than a %s digest (%d characters)", n, algorithm, hex.EncodedLen(len(sum)))
+		}
+	}
+	return func(sum []byte) string {
+		return hex.EncodeToString(sum[:n/2])
+	}, nil
+}
+
+// sumDecoder parses a digest rendered by the matching sumEncoder.
+type sumDecoder func(s string) ([]byte, error)
+
+// decoders maps -encoding names to their sumDecoder.
+var decoders = map[string]sumDecoder{
+	"hex":       hex.DecodeString,
+	"base64":    base64.StdEncoding.DecodeString,
+	"base64url": base64.RawURLEncoding.DecodeString,
+	"base32": func(s string) ([]byte, error) {
+		return base32NoPad.DecodeString(strings.ToUpper(s))
+	},
+}
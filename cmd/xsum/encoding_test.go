@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+func TestShortEncoder(t *testing.T) {
+	srv, err := xsum.NewServer("md5", "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	encode, err := shortEncoder(8, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encode([]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}); got != "01234567" {
+		t.Errorf("got %q, want 01234567", got)
+	}
+	for _, n := range []int{0, 7, 34} {
+		if _, err := shortEncoder(n, srv); err == nil {
+			t.Errorf("expected -short %d to be rejected", n)
+		}
+	}
+}
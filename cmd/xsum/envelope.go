@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is written by -envelope: as the "version" field of the JSON
+// envelope and in the "# xsum-version: N" first line of CSV output.
+//
+// It is bumped whenever a consumer written for the previous version could
+// misread the output: a column or field is renamed or removed, or the meaning
+// or encoding of an existing one changes. Adding a column or field does not
+// bump it, so consumers should ignore names they do not know. Manifests with
+// a newer version than this build supports are refused by -audit.
+const schemaVersion = 1
+
+// csvVersionPrefix starts the first line of versioned CSV output.
+const csvVersionPrefix = "# xsum-version:"
+
+// checkSchemaVersion rejects manifests written for a newer schema.
+func checkSchemaVersion(version int) error {
+	if version > schemaVersion {
+		return fmt.Errorf("manifest schema version %d is newer than supported version %d", version, schemaVersion)
+	}
+	return nil
+}
+
+// parseCSVVersion returns the version from a "# xsum-version: N" line.
+func parseCSVVersion(line string) (int, bool) {
+	value, ok := strings.CutPrefix(line, csvVersionPrefix)
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(value))
+	return version, err == nil
+}
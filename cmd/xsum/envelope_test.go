@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for format, newWriter := range map[string]func(*bytes.Buffer, writerConfig) xsumWriter{
+		"csv":  func(b *bytes.Buffer, cfg writerConfig) xsumWriter { return newCsvWriter(b, cfg) },
+		"json": func(b *bytes.Buffer, cfg writerConfig) xsumWriter { return newJSONWriter(b, cfg) },
+	} {
+		var buf bytes.Buffer
+		w := newWriter(&buf, writerConfig{algorithms: []string{"sha256"}, encode: encoders["hex"], envelope: true})
+		if err := w.Write("host", "/a", 1, map[string][]byte{"sha256": {0xab}}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write("host", "/b", 2, map[string][]byte{"sha256": {0xcd}}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		filename := filepath.Join(dir, "manifest."+format)
+		if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := readManifest(filename, decoders["hex"])
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, buf.Bytes())
+		}
+		if len(m.entries) != 2 || m.entries[1].filename != "/b" || m.entries[1].sums["sha256"][0] != 0xcd {
+			t.Fatalf("%s: unexpected entries %+v", format, m.entries)
+		}
+
+		newer := bytes.Replace(buf.Bytes(), []byte("1"), []byte("9"), 1)
+		if err := os.WriteFile(filename, newer, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readManifest(filename, decoders["hex"]); err == nil {
+			t.Fatalf("%s: expected a newer schema version to be refused:\n%s", format, newer)
+		}
+	}
+}
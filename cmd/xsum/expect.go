@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// algorithmsByHexLen maps a hex digest length to the algorithm -expect assumes.
+var algorithmsByHexLen = map[int]string{
+	32:  "md5",
+	40:  "sha1",
+	64:  "sha256",
+	128: "sha512",
+}
+
+// expectAlgorithm picks the one algorithm in candidates whose digest is
+// size bytes long.
+func expectAlgorithm(candidates []string, size int) (string, error) {
+	srv, err := xsum.NewServer(candidates...)
+	if err != nil {
+		return "", err
+	}
+	defer srv.Close()
+	h := srv.NewHash()
+	defer h.Close()
+	var matches []string
+	for _, algorithm := range srv.Algorithms() {
+		if len(h.MultiSum()[algorithm]) == size {
+			matches = append(matches, algorithm)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no algorithm in -a gives a %d digit hash", size*2)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("a %d digit hash matches %s, use -a with one of them", size*2, strings.Join(matches, ", "))
+	}
+}
+
+// chooseExpectAlgorithm returns the algorithm for a size byte digest: the
+// one in -a with that size when -a is given, otherwise the one inferred from
+// the size. A weak choice is warned about on w either way.
+func chooseExpectAlgorithm(w io.Writer, size int) (string, error) {
+	algorithm, ok := algorithmsByHexLen[size*2]
+	if flagSet("a") {
+		var err error
+		if algorithm, err = expectAlgorithm(strings.Split(algorithmFlag, ","), size); err != nil {
+			return "", err
+		}
+	} else if !ok {
+		return "", fmt.Errorf("cannot infer algorithm from a %d digit hash, use -a", size*2)
+	}
+	warnWeak(w, []string{algorithm})
+	return algorithm, nil
+}
+
+// doExpect hashes filename and compares it to the expected hex digest, in
+// either case. The algorithm is the one in -a with a digest of that length
+// when -a is given, otherwise inferred from the digest length. It prints the
+// outcome and reports whether it matched.
+func doExpect(ctx context.Context, expected string, filename string) (match bool, err error) {
+	want, err := hex.DecodeString(strings.TrimSpace(expected))
+	if err != nil {
+		return false, fmt.Errorf("invalid expected hash: %w", err)
+	}
+	algorithm, err := chooseExpectAlgorithm(os.Stderr, len(want))
+	if err != nil {
+		return false, err
+	}
+
+	srv, err := xsum.NewServer(algorithm)
+	if err != nil {
+		return false, err
+	}
+	defer srv.Close()
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	sums, err := xsum.HashReader(srv, f)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+
+	got := sums[algorithm]
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		fmt.Printf("%s: MISMATCH (%s expected %x, got %x)\n", filename, algorithm, want, got)
+		return false, nil
+	}
+	fmt.Printf("%s: OK (%s)\n", filename, algorithm)
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpectAlgorithm(t *testing.T) {
+	for _, tc := range []struct {
+		candidates []string
+		size       int
+		want       string
+	}{
+		{[]string{"sha256", "md5"}, 32, "sha256"},
+		{[]string{"sha256", "md5"}, 16, "md5"},
+		{[]string{"sha1", "sha512", "xxh64"}, 8, "xxh64"},
+		{[]string{"sha256", "md5"}, 20, ""},
+		{[]string{"sha256", "blake3"}, 32, ""},
+	} {
+		got, err := expectAlgorithm(tc.candidates, tc.size)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%v/%d: expected an error, got %s", tc.candidates, tc.size, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%v/%d: got %q, %v, want %s", tc.candidates, tc.size, got, err, tc.want)
+		}
+	}
+}
+
+func TestExpectInferredWeakWarns(t *testing.T) {
+	defer func(allow bool) { allowWeakFlag = allow }(allowWeakFlag)
+	allowWeakFlag = false
+
+	for size, want := range map[int]string{16: "md5", 20: "sha1", 32: "sha256"} {
+		var out strings.Builder
+		algorithm, err := chooseExpectAlgorithm(&out, size)
+		if err != nil || algorithm != want {
+			t.Fatalf("%d bytes: got %q, %v, want %s", size, algorithm, err, want)
+		}
+		if warned := strings.Contains(out.String(), want); warned != weakAlgorithms[want] {
+			t.Errorf("%s: warned %v, got %q", want, warned, out.String())
+		}
+	}
+	if _, err := chooseExpectAlgorithm(&strings.Builder{}, 7); err == nil {
+		t.Error("expected an error for a digest length no algorithm has")
+	}
+}
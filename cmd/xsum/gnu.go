@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gnuEscaper escapes filenames the way GNU coreutils does, for lines that
+// start with a backslash.
+var gnuEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// gnuWriter emits "<sum>  <filename>" lines that sha256sum -c and friends
+// accept. It takes exactly one algorithm and hex encoding. Like coreutils,
+// filenames containing a backslash or newline are escaped and their line
+// starts with a backslash. Rows that failed go to stderr instead.
+type gnuWriter struct {
+	w         *bufio.Writer
+	algorithm string
+	encode    sumEncoder
+}
+
+func newGnuWriter(w io.Writer, cfg writerConfig) *gnuWriter {
+	return &gnuWriter{w: bufio.NewWriter(w), algorithm: cfg.algorithms[0], encode: cfg.encode}
+}
+
+// checkGnuOutput reports why the gnu format cannot represent the output, if
+// it cannot.
+func checkGnuOutput(algorithms []string, encoding string) error {
+	if len(algorithms) != 1 {
+		return fmt.Errorf("-f gnu takes exactly one algorithm, got %s", strings.Join(algorithms, ","))
+	}
+	if encoding != "hex" {
+		return fmt.Errorf("-f gnu requires hex encoding, not %s", encoding)
+	}
+	return nil
+}
+
+// Close implements xsumWriter.
+func (w *gnuWriter) Close() error {
+	return w.w.Flush()
+}
+
+// Write implements xsumWriter.
+func (w *gnuWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return nil
+	}
+	if strings.ContainsAny(filename, "\\\n\r") {
+		w.w.WriteByte('\\')
+		filename = gnuEscaper.Replace(filename)
+	}
+	_, err = fmt.Fprintf(w.w, "%s  %s\n", w.encode(sums[w.algorithm]), filename)
+	return err
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGnuWriter(t *testing.T) {
+	var sb strings.Builder
+	w := newGnuWriter(&sb, writerConfig{algorithms: []string{"sha256"}, encode: encoders["hex"]})
+	sum := map[string][]byte{"sha256": {0xab, 0xcd}}
+	for _, name := range []string{"plain.txt", `back\slash`, "new\nline"} {
+		if err := w.Write("host", name, 1, sum, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "abcd  plain.txt\n\\abcd  back\\\\slash\n\\abcd  new\\nline\n"
+	if sb.String() != want {
+		t.Fatalf("got %q, want %q", sb.String(), want)
+	}
+
+	if err := checkGnuOutput([]string{"sha256", "md5"}, "hex"); err == nil {
+		t.Error("expected more than one algorithm to be rejected")
+	}
+	if err := checkGnuOutput([]string{"sha256"}, "base64"); err == nil {
+		t.Error("expected base64 encoding to be rejected")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileID has no inode information on this platform, so -H never finds
+// hardlinks and every path is hashed.
+func fileID(fs.FileInfo) (inode, bool) { return inode{}, false }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cancelWriter cancels the run once the first row has been written.
+type cancelWriter struct {
+	xsumWriter
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	defer w.cancel()
+	return w.xsumWriter.Write(hostname, filename, size, sums, err)
+}
+
+func TestDoXsumInterruptedOutputIsValid(t *testing.T) {
+	defer func(format, out string, cache bool) {
+		outputFlag, outFileFlag, cacheFlag = format, out, cache
+	}(outputFlag, outFileFlag, cacheFlag)
+	dir := t.TempDir()
+	outputFlag, outFileFlag, cacheFlag = "cancel-json", filepath.Join(dir, "out.json"), false
+
+	const files = 200
+	var filenames []string
+	for i := range files {
+		filename := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(filename, []byte(filename), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	// The run is interrupted from inside, after its first row.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writers["cancel-json"] = func(w io.Writer, cfg writerConfig) xsumWriter {
+		return &cancelWriter{newJSONWriter(w, cfg), cancel}
+	}
+	defer delete(writers, "cancel-json")
+	if err := doXsum(ctx, filenames, []string{"sha256"}); err != nil {
+		t.Fatalf("doXsum: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the run to be cancelled")
+	}
+	b, err := os.ReadFile(outFileFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) == 0 || !bytes.HasSuffix(b, []byte("\n")) {
+		t.Fatalf("interrupted run did not end on a full line: %q", b)
+	}
+	rows := 0
+	for line := range bytes.Lines(b) {
+		if !json.Valid(line) {
+			t.Fatalf("interrupted run wrote invalid JSON: %q", line)
+		}
+		rows++
+	}
+	if rows == files {
+		t.Errorf("expected the interruption to stop the run before all %d files", files)
+	}
+}
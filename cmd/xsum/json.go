@@ -6,32 +6,98 @@ import (
 	"io"
 )
 
+// jsonWriter writes one JSON object per line, or with -envelope a single
+// {"version":N,"files":[...]} document. With -summary a final
+// {"summary":true,...} object follows the files, or with -envelope a
+// "summary" field follows "files".
 type jsonWriter struct {
-	enc *json.Encoder
+	w         io.Writer
+	enc       *json.Encoder
+	encode    sumEncoder
+	humanSize bool
+	envelope  bool
+	records   int
+	summary   *outputSummary
 }
 
-func newJSONWriter(w io.Writer) *jsonWriter {
-	return &jsonWriter{enc: json.NewEncoder(w)}
+func newJSONWriter(w io.Writer, cfg writerConfig) *jsonWriter {
+	jw := &jsonWriter{w: w, enc: json.NewEncoder(w), encode: cfg.encode, humanSize: cfg.humanSize, envelope: cfg.envelope}
+	if cfg.summary {
+		jw.summary = newOutputSummary()
+	}
+	return jw
 }
 
 // Close implements xsumWriter.
-func (*jsonWriter) Close() error { return nil }
+func (w *jsonWriter) Close() error {
+	if !w.envelope {
+		if w.summary == nil {
+			return nil
+		}
+		return w.enc.Encode(w.summary.record())
+	}
+	if w.records == 0 {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	end := "\n]}\n"
+	if w.summary != nil {
+		record := w.summary.record()
+		delete(record, "summary")
+		b, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		end = "\n],\"summary\":" + string(b) + "}\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+// open writes the start of the envelope.
+func (w *jsonWriter) open() error {
+	_, err := fmt.Fprintf(w.w, "{\"version\":%d,\"files\":[", schemaVersion)
+	return err
+}
 
 // Write implements xsumWriter.
 func (w *jsonWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	if w.summary != nil {
+		w.summary.add(size)
+	}
 	data := map[string]any{
 		"hostname": hostname,
 		"filename": filename,
 		"size":     size,
 	}
+	if w.humanSize {
+		data["size_human"] = formatSize(size)
+	}
 	if err != nil {
 		data["error"] = err.Error()
 	} else {
 		for algorithm, sum := range sums {
-			data[algorithm+"sum"] = fmt.Sprintf("%x", sum)
+			data[algorithm+"sum"] = w.encode(sum)
 		}
 	}
-	return w.enc.Encode(data)
+	if !w.envelope {
+		return w.enc.Encode(data)
+	}
+	sep := ",\n"
+	if w.records == 0 {
+		if err := w.open(); err != nil {
+			return err
+		}
+		sep = "\n"
+	}
+	w.records++
+	b, mErr := json.Marshal(data)
+	if mErr != nil {
+		return mErr
+	}
+	_, wErr := io.WriteString(w.w, sep+string(b))
+	return wErr
 }
 
 var _ xsumWriter = new(jsonWriter)
@@ -2,27 +2,72 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ophymx/utils/xsum"
+	"github.com/ophymx/utils/xsum/sqlitecache"
 )
 
 var (
-	helpFlag      bool
-	cacheFlag     bool
-	versionFlag   bool
-	verboseFlag   bool
-	outputFlag    string
-	algorithmFlag string
+	helpFlag        bool
+	cacheFlag       bool
+	cacheNSFlag     string
+	cacheDBFlag     string
+	noCacheWrite    bool
+	versionFlag     bool
+	verboseFlag     bool
+	vvFlag          bool
+	affinityFlag    bool
+	maxOpenFlag     int
+	jobsFlag        int
+	followFlag      bool
+	allowWeakFlag   bool
+	auditFlag       string
+	humanFlag       bool
+	warnEmptyFlag   bool
+	hardlinkFlag    bool
+	treeFlag        bool
+	summaryFlag     string
+	failFastFlag    bool
+	outputFlag      string
+	outFileFlag     string
+	compressFlag    bool
+	baseDirFlag     string
+	baseStrict      bool
+	gitRootFlag     bool
+	recursiveFlag   bool
+	shortFlag       int
+	diffFlag        bool
+	progressFlag    bool
+	orderedFlag     bool
+	followLinksFlag bool
+	gitFallbackFlag bool
+	expectFlag      string
+	bufSizeFlag     string
+	sortFlag        bool
+	benchFlag       bool
+	nulFlag         bool
+	chunkFlag       string
+	syslogFlag      bool
+	envelopeFlag    bool
+	summaryRowFlag  bool
+	checkFlag       string
+	verifySigFlag   string
+	stdinFlag       bool
+	nulInFlag       bool
+	algorithmFlag   string
+	encodingFlag    string
 )
 
 const version = "0.2"
@@ -30,15 +75,78 @@ const version = "0.2"
 func init() {
 	flag.BoolVar(&helpFlag, "h", false, "Display help")
 	flag.BoolVar(&cacheFlag, "c", true, "Use cache")
+	flag.StringVar(&cacheNSFlag, "cache-ns", defaultCacheNS, "Extended attribute namespace for the cache")
+	flag.StringVar(&cacheDBFlag, "cache-db", "", "Cache sums in the SQLite database `file` instead of extended attributes, keeping every scan")
+	flag.BoolVar(&noCacheWrite, "no-cache-write", false, "Read sums from the cache but never write to it (read-only filesystems are detected automatically)")
 	flag.BoolVar(&versionFlag, "V", false, "Display version")
-	flag.BoolVar(&verboseFlag, "v", false, "Verbose output")
-	flag.StringVar(&outputFlag, "f", "csv", "Output format (csv, json)")
-	flag.StringVar(&algorithmFlag, "a", "sha256,md5", "Algorithms (comma separated)")
+	flag.BoolVar(&verboseFlag, "v", false, "Verbose output, report the CPU features and hash implementations in use to stderr")
+	flag.BoolVar(&vvFlag, "vv", false, "Very verbose output, report per-file hashing time to stderr (implies -v)")
+	flag.StringVar(&outputFlag, "f", "csv", "Output format (csv, json, shields, nul, gnu); gnu writes sha256sum -c style lines for a single algorithm")
+	flag.BoolVar(&envelopeFlag, "envelope", false, "Mark output with its schema version: a {\"version\":N,\"files\":[...]} JSON document, or a \"# xsum-version: N\" first CSV line")
+	flag.StringVar(&outFileFlag, "o", "", "Write output to `file` instead of stdout (gzip-compressed if it ends in .gz)")
+	flag.BoolVar(&compressFlag, "compress", false, "Gzip-compress the output")
+	flag.StringVar(&baseDirFlag, "base-dir", "", "Write filenames relative to `dir`; files outside it keep their absolute path")
+	flag.BoolVar(&baseStrict, "base-dir-strict", false, "Report files outside -base-dir as errors instead of writing absolute paths")
+	flag.BoolVar(&gitRootFlag, "relative-to-git-root", false, "Write filenames relative to the root of the enclosing git repository, wherever xsum is run from")
+	flag.BoolVar(&gitFallbackFlag, "git-root-fallback", false, "With -relative-to-git-root, use the working directory outside a repository instead of failing")
+	flag.StringVar(&expectFlag, "expect", "", "Check a single file against a hex `hash`; the algorithm is inferred from its length, or picked from -a by it")
+	flag.StringVar(&algorithmFlag, "a", defaultAlgorithms, "Algorithms (comma separated: md5, sha1, sha256, sha512, sha3-256, sha3-512, blake3, and the non-cryptographic xxh64, xxh3, crc32, crc64)")
+	flag.StringVar(&encodingFlag, "encoding", "hex", "Sum encoding (hex, base64, base64url, base32) for every output format and -tree, and for reading manifests")
+	flag.IntVar(&shortFlag, "short", 0, "Print only the first `N` hex characters of each sum, for display (not with -check, -audit, -expect or -f gnu)")
+	flag.BoolVar(&affinityFlag, "affinity", false, "Pin each worker to its own CPU (Linux only)")
+	flag.BoolVar(&failFastFlag, "fail-fast", false, "Stop at the first file that cannot be read and exit non-zero")
+	flag.BoolFunc("continue-on-error", "Write failures to the error column, process every file and exit non-zero at the end (default)", func(string) error {
+		failFastFlag = false
+		return nil
+	})
+	flag.BoolVar(&summaryRowFlag, "summary", false, "End csv and json output with a record of the file count, total bytes and elapsed time")
+	flag.StringVar(&summaryFlag, "summary-json", "", "Write a JSON summary of the run (counts, bytes, throughput) to `file`")
+	flag.StringVar(&chunkFlag, "chunk", "", "Split files into content-defined chunks of about `size` bytes (e.g. 64K) and write a row per chunk with its offset and length")
+	flag.BoolVar(&treeFlag, "tree", false, "Print a Merkle digest per directory and whether it changed since the last run")
+	flag.BoolVar(&recursiveFlag, "r", false, "Hash every regular file under directories given as arguments")
+	flag.BoolVar(&followLinksFlag, "L", false, "With -r, follow symlinks to files and directories")
+	flag.BoolVar(&hardlinkFlag, "H", false, "Hash hardlinked files once and reuse the sums for every path (Unix only)")
+	flag.BoolVar(&progressFlag, "progress", false, "Show files and bytes done and the throughput on stderr while hashing")
+	flag.BoolVar(&warnEmptyFlag, "warn-empty", false, "Warn about zero-byte files, which may be truncated")
+	flag.BoolVar(&humanFlag, "human", false, "Print sizes in human readable IEC units (e.g. 1.4 GiB)")
+	flag.StringVar(&checkFlag, "check", "", "Verify the files listed in a manifest `file` and print OK, FAILED or MISSING for each, like sha256sum -c")
+	flag.StringVar(&verifySigFlag, "verify-sig", "", "With -check, require a valid minisign signature in manifest.minisig from this public `key` (or .pub file)")
+	flag.BoolVar(&diffFlag, "diff", false, "Compare two manifests and print the files ADDED, REMOVED or CHANGED between them (every file with -v), without reading the files")
+	flag.StringVar(&auditFlag, "audit", "", "Compare the files under the given directories against a manifest")
+	flag.BoolVar(&allowWeakFlag, "allow-weak", os.Getenv("XSUM_ALLOW_WEAK") != "", "Do not warn about weak algorithms (or set XSUM_ALLOW_WEAK)")
+	flag.BoolVar(&followFlag, "follow", false, "Hash growing files only up to their size when opened")
+	flag.BoolVar(&syslogFlag, "syslog", false, "Also send failures, audit drift and a run summary to syslog (for unattended scans)")
+	flag.BoolVar(&stdinFlag, "stdin", false, "Read the files to hash from stdin, one per line, in addition to any arguments (also \"-\" as an argument)")
+	flag.BoolVar(&nulInFlag, "0", false, "Read NUL-separated file names from stdin, as written by find -print0 (implies -stdin)")
+	flag.BoolVar(&nulFlag, "z", false, "NUL-terminated output, each sum then the filename (same as -f nul)")
+	flag.BoolVar(&benchFlag, "bench", false, "Measure the throughput of each algorithm on in-memory data and show which implementation is used")
+	flag.BoolVar(&sortFlag, "sort", false, "Sort output by filename; all rows are held in memory until the run ends")
+	flag.BoolVar(&orderedFlag, "ordered", false, "Write rows in the order files were given (directories with -r in walk order), holding only rows that finish early")
+	flag.StringVar(&bufSizeFlag, "bufsize", "1M", "Read buffer `size` per file (e.g. 256K for network filesystems, 4M or more for fast NVMe)")
+	flag.IntVar(&jobsFlag, "j", 0, "Number of files hashed at once (0 for one per CPU, up to 16)")
+	flag.IntVar(&maxOpenFlag, "max-open", defaultMaxOpenFiles(), "Maximum number of files open at once (0 for no limit)")
 	flag.Usage = func() {
 		println("Usage: xsum [options] file1 file2 ...")
+		println("       find . -type f | xsum [options]")
+		println("       xsum -r dir1 dir2 ...")
+		println("       xsum -audit manifest dir1 dir2 ...")
+		println("       xsum -check manifest")
+		println("       xsum -diff old-manifest new-manifest")
+		println("       xsum -tree dir1 dir2 ...")
+		println("       xsum -expect hash file")
+		println("       xsum -chunk size file1 file2 ...")
+		println("       xsum -bench")
 		println()
 		println("xsum - calculate checksums of files in parallel")
 		println()
+		println("Defaults for -a, -f, -encoding, -c and -cache-ns can be set in a .xsum file")
+		println("in the working directory (algorithms, format, encoding, cache, cache-ns = value);")
+		println("XSUM_ALGORITHMS overrides the file and flags override both.")
+		println()
+		println("With -envelope the output carries a schema version, bumped only when a")
+		println("column or field is renamed, removed or changes meaning; new columns and")
+		println("fields may appear without a bump.")
+		println()
 		flag.PrintDefaults()
 	}
 }
@@ -48,13 +156,164 @@ type xsumWriter interface {
 	Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error
 }
 
-var writers = map[string]func(w io.Writer, algorithms []string) xsumWriter{
-	"json": func(w io.Writer, algorithms []string) xsumWriter {
-		return newJSONWriter(w)
+// writerConfig holds the settings shared by every output writer.
+type writerConfig struct {
+	algorithms []string
+	encode     sumEncoder
+	humanSize  bool
+	// envelope adds a schema version to csv and json output.
+	envelope bool
+	// summary adds a trailing record with totals to csv and json output.
+	summary bool
+}
+
+var writers = map[string]func(w io.Writer, cfg writerConfig) xsumWriter{
+	"json": func(w io.Writer, cfg writerConfig) xsumWriter {
+		return newJSONWriter(w, cfg)
+	},
+	"csv": func(w io.Writer, cfg writerConfig) xsumWriter {
+		return newCsvWriter(w, cfg)
+	},
+	"shields": func(w io.Writer, cfg writerConfig) xsumWriter {
+		return newShieldsWriter(w, cfg)
 	},
-	"csv": func(w io.Writer, algorithms []string) xsumWriter {
-		return newCsvWriter(w, algorithms)
+	"nul": func(w io.Writer, cfg writerConfig) xsumWriter {
+		return newNulWriter(w, cfg)
 	},
+	"gnu": func(w io.Writer, cfg writerConfig) xsumWriter {
+		return newGnuWriter(w, cfg)
+	},
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// weakAlgorithms are digests that are no longer collision resistant.
+var weakAlgorithms = map[string]bool{
+	"md5":  true,
+	"sha1": true,
+}
+
+// defaultAlgorithms is the built-in -a.
+const defaultAlgorithms = "sha256,md5"
+
+// warnWeak prints a warning to w for each weak algorithm chosen, whether
+// with -a, a .xsum file or XSUM_ALGORITHMS. Only the built-in default list,
+// in any order, is not warned about.
+func warnWeak(w io.Writer, algorithms []string) {
+	if allowWeakFlag {
+		return
+	}
+	chosen := slices.Compact(slices.Sorted(slices.Values(algorithms)))
+	defaults := slices.Sorted(slices.Values(strings.Split(defaultAlgorithms, ",")))
+	if slices.Equal(chosen, defaults) {
+		return
+	}
+	warned := make(map[string]bool)
+	for _, algorithm := range algorithms {
+		if weakAlgorithms[algorithm] && !warned[algorithm] {
+			warned[algorithm] = true
+			fmt.Fprintf(w, "warning: %s is cryptographically broken; use only for non-security checks\n", algorithm)
+		}
+	}
+}
+
+// reportHashed prints how long a file took to hash and the throughput.
+func reportHashed(filename string, stats xsum.HashStats) {
+	rate := float64(stats.Bytes) / max(stats.Elapsed.Seconds(), 1e-9)
+	fmt.Fprintf(os.Stderr, "%s: %s in %s (%s/s)\n", filename, formatSize(stats.Bytes), stats.Elapsed.Round(time.Microsecond), formatSize(int64(rate)))
+}
+
+// exitInterrupted is the exit status after SIGINT or SIGTERM, as a shell
+// reports for a process killed by SIGINT.
+const exitInterrupted = 130
+
+// exitIfInterrupted exits with exitInterrupted if ctx was cancelled by a
+// signal. Callers check after their writers are closed, so the output up to
+// the interruption is complete.
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "interrupted")
+		os.Exit(exitInterrupted)
+	}
+}
+
+// logger receives failures and run summaries with -syslog. *syslog.Writer
+// implements it.
+type logger interface {
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Close() error
+}
+
+// sysLog is nil unless -syslog is set.
+var sysLog logger
+
+// logFailure sends a per-file failure to syslog with -syslog.
+func logFailure(filename string, err error) {
+	if sysLog != nil {
+		sysLog.Err(fmt.Sprintf("%s: %s", filename, err))
+	}
+}
+
+// logSummary sends a run summary to syslog with -syslog, as a warning if
+// any file failed or drifted.
+func logSummary(problems int, format string, args ...any) {
+	if sysLog == nil {
+		return
+	}
+	if problems > 0 {
+		sysLog.Warning(fmt.Sprintf(format, args...))
+	} else {
+		sysLog.Info(fmt.Sprintf(format, args...))
+	}
+}
+
+// outputName is the filename written for name: relative to -base-dir when
+// it is set, and URLs as given.
+func outputName(name string) string {
+	if isURL(name) {
+		return name
+	}
+	outName, _ := relToBase(name)
+	return outName
+}
+
+// errIsDirectory is reported for directories given as inputs, as sha256sum does.
+var errIsDirectory = errors.New("is a directory")
+
+// errNotRegular is reported for devices, pipes and sockets given as inputs,
+// which could block or never end if read.
+var errNotRegular = errors.New("not a regular file")
+
+// notRegularError wraps errNotRegular with what the file is, and whether it
+// was reached through a symlink.
+func notRegularError(mode fs.FileMode, viaLink bool) error {
+	kind := "irregular file"
+	switch {
+	case mode&fs.ModeCharDevice != 0:
+		kind = "character device"
+	case mode&fs.ModeDevice != 0:
+		kind = "block device"
+	case mode&fs.ModeNamedPipe != 0:
+		kind = "named pipe"
+	case mode&fs.ModeSocket != 0:
+		kind = "socket"
+	}
+	if viaLink {
+		kind = "symlink to " + kind
+	}
+	return fmt.Errorf("%w (%s)", errNotRegular, kind)
+}
+
+// inode identifies a file independent of the path it was reached by.
+type inode struct {
+	dev, ino uint64
 }
 
 func doXsum(ctx context.Context, filenames []string, algorithms []string) (err error) {
@@ -62,17 +321,146 @@ func doXsum(ctx context.Context, filenames []string, algorithms []string) (err e
 	if !ok {
 		return fmt.Errorf("unknown output format: %s", outputFlag)
 	}
-	writer := newWriter(os.Stdout, algorithms)
-	defer writer.Close()
+	encode, ok := encoders[encodingFlag]
+	if !ok {
+		return fmt.Errorf("unknown encoding: %s", encodingFlag)
+	}
+	if envelopeFlag && outputFlag != "csv" && outputFlag != "json" {
+		return fmt.Errorf("-envelope supports csv and json output, not %s", outputFlag)
+	}
+	if summaryRowFlag && outputFlag != "csv" && outputFlag != "json" {
+		return fmt.Errorf("-summary supports csv and json output, not %s", outputFlag)
+	}
+	if sortFlag && orderedFlag {
+		return errors.New("-sort and -ordered are mutually exclusive")
+	}
+	srv, err := xsum.NewServer(algorithms...)
+	if err != nil {
+		return
+	}
+	defer srv.Close()
+	if outputFlag == "gnu" {
+		if err = checkGnuOutput(srv.Algorithms(), encodingFlag); err != nil {
+			return
+		}
+	}
+	if shortFlag != 0 {
+		if encodingFlag != "hex" || outputFlag == "gnu" {
+			return fmt.Errorf("-short needs hex encoding and is for display only, not -encoding %s -f %s", encodingFlag, outputFlag)
+		}
+		if encode, err = shortEncoder(shortFlag, srv); err != nil {
+			return
+		}
+	}
+
+	out, err := openOutput(outFileFlag, compressFlag)
+	if err != nil {
+		return
+	}
+	writer := newWriter(out, writerConfig{
+		algorithms: srv.Algorithms(),
+		encode:     encode,
+		humanSize:  humanFlag,
+		envelope:   envelopeFlag,
+		summary:    summaryRowFlag,
+	})
+	if sortFlag {
+		writer = newSortedWriter(writer)
+	}
+	// expect ranks a name for -ordered as the inputs are read.
+	expect := func(string) {}
+	if orderedFlag {
+		ordered := newOrderedWriter(writer)
+		expect = func(name string) { ordered.expect(outputName(name)) }
+		writer = ordered
+	}
+	defer func() {
+		if cErr := writer.Close(); err == nil {
+			err = cErr
+		}
+		if cErr := out.Close(); err == nil {
+			err = cErr
+		}
+	}()
 
 	var hostname string
 	if hostname, err = os.Hostname(); err != nil {
 		return
 	}
+
+	var summary *runSummary
+	if summaryFlag != "" {
+		summary = newRunSummary(srv.Algorithms())
+		defer func() {
+			if sErr := summary.write(summaryFlag); err == nil {
+				err = sErr
+			}
+		}()
+	}
+
+	// report writes one row. With -fail-fast the first failure is returned
+	// as an error; otherwise failures are counted and reported once all
+	// files have been processed.
+	rows, failed := 0, 0
+	report := func(name string, size int64, sums map[string][]byte, hashed bool, sErr error) error {
+		rows++
+		if summary != nil {
+			summary.add(name, size, hashed, sErr)
+		}
+		if err := writer.Write(hostname, outputName(name), size, sums, sErr); err != nil {
+			return err
+		}
+		if sErr != nil {
+			failed++
+			logFailure(name, sErr)
+			if failFastFlag {
+				if _, ok := errors.AsType[*fs.PathError](sErr); ok {
+					return sErr
+				}
+				return fmt.Errorf("%s: %w", name, sErr)
+			}
+		}
+		return nil
+	}
+	defer func() {
+		logSummary(failed, "%d files, %d failed", rows, failed)
+		if err == nil && failed > 0 {
+			err = fmt.Errorf("%d of %d files failed", failed, rows)
+		}
+	}()
+
 	seen := make(map[string]struct{}, len(filenames))
 	sizes := make(map[string]int64, len(filenames))
-	uniq := filenames[:0]
+	uniq := make([]string, 0, len(filenames))
+	var urls []string
+	inodes := make(map[inode]string)
+	links := make(map[string][]string)
+	// add queues a regular file for hashing, once per inode with -H.
+	add := func(filename string, info fs.FileInfo) {
+		sizes[filename] = info.Size()
+		if warnEmptyFlag && info.Size() == 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s is empty\n", filename)
+		}
+		if hardlinkFlag {
+			if id, ok := fileID(info); ok {
+				if first, ok := inodes[id]; ok {
+					links[first] = append(links[first], filename)
+					return
+				}
+				inodes[id] = filename
+			}
+		}
+		uniq = append(uniq, filename)
+	}
 	for _, filename := range filenames {
+		if isURL(filename) {
+			if _, ok := seen[filename]; !ok {
+				seen[filename] = struct{}{}
+				expect(filename)
+				urls = append(urls, filename)
+			}
+			continue
+		}
 		if filename, err = filepath.Abs(filename); err != nil {
 			return
 		}
@@ -80,35 +468,157 @@ func doXsum(ctx context.Context, filenames []string, algorithms []string) (err e
 			continue
 		}
 		seen[filename] = struct{}{}
-		var info fs.FileInfo
-		if info, err = os.Stat(filename); err != nil {
-			return
+		if _, ok := relToBase(filename); !ok && baseStrict {
+			if err = report(filename, 0, nil, false, errOutsideBase); err != nil {
+				return
+			}
+			continue
 		}
-		sizes[filename] = info.Size()
-		uniq = append(uniq, filename)
+		// A symlink named on the command line is hashed as its target, as
+		// sha256sum does, but reported as a link if that is not a file.
+		info, sErr := os.Lstat(filename)
+		viaLink := sErr == nil && info.Mode()&fs.ModeSymlink != 0
+		if viaLink {
+			info, sErr = os.Stat(filename)
+		}
+		if sErr != nil {
+			if err = report(filename, 0, nil, false, sErr); err != nil {
+				return
+			}
+			continue
+		}
+		if info.IsDir() && recursiveFlag {
+			err = walkTree(filename, followLinksFlag, func(path string, info fs.FileInfo, wErr error) error {
+				if wErr != nil {
+					return report(path, 0, nil, false, wErr)
+				}
+				if _, ok := seen[path]; !ok {
+					seen[path] = struct{}{}
+					expect(path)
+					add(path, info)
+				}
+				return nil
+			})
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if info.IsDir() {
+			if err = report(filename, 0, nil, false, errIsDirectory); err != nil {
+				return
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			if err = report(filename, 0, nil, false, notRegularError(info.Mode(), viaLink)); err != nil {
+				return
+			}
+			continue
+		}
+		// Only inputs that will be hashed are ranked for -ordered; rows for
+		// errors above are ranked as they are written, and a directory
+		// walked with -r never gets a row of its own to wait for.
+		expect(filename)
+		add(filename, info)
 	}
-
-	srv, err := xsum.NewServer(algorithms...)
-	if err != nil {
-		return
+	if outputFlag == "shields" {
+		// Refuse before hashing anything rather than on the second row.
+		n := rows + len(urls) + len(uniq)
+		for _, dups := range links {
+			n += len(dups)
+		}
+		if n != 1 {
+			return fmt.Errorf("-f shields takes exactly one file, got %d", n)
+		}
 	}
-	defer srv.Close()
 
 	var cache xsum.Cache
-	if cacheFlag {
-		cache = newXattrCache()
+	if cacheFlag && cacheDBFlag != "" {
+		dbCache, err := sqlitecache.New(cacheDBFlag)
+		if err != nil {
+			return err
+		}
+		defer dbCache.Close()
+		cache = dbCache
+		if noCacheWrite {
+			cache = readOnlyCache{dbCache}
+		}
+	} else if cacheFlag {
+		xc := newXattrCache(cacheNSFlag)
+		xc.readOnly = noCacheWrite
+		cache = xc
 	}
-	xsum.Parallel(ctx, srv, cache, uniq, func(filename string, sums map[string][]byte, err error) {
-		if e := writer.Write(hostname, filename, sizes[filename], sums, err); e != nil {
-			log.Panicln(e)
+	bufSize, err := parseSize(bufSizeFlag)
+	if err != nil || bufSize == 0 || bufSize > 1<<30 {
+		return fmt.Errorf("invalid -bufsize %s", bufSizeFlag)
+	}
+	opts := xsum.Options{Affinity: affinityFlag, Workers: jobsFlag, MaxOpenFiles: maxOpenFlag, Snapshot: followFlag, BufferSize: int(bufSize)}
+	opts.OnHashed = func(filename string, stats xsum.HashStats) {
+		if summary != nil {
+			summary.onHashed(filename, stats)
+		}
+		if vvFlag {
+			reportHashed(filename, stats)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var prog *progress
+	if progressFlag {
+		var total int64
+		for _, filename := range uniq {
+			total += sizes[filename]
+		}
+		prog = startProgress(os.Stderr, len(uniq), total)
+	}
+	xsum.ParallelOptions(ctx, srv, cache, uniq, opts, func(filename string, sums map[string][]byte, sErr error) {
+		if prog != nil {
+			prog.add(sizes[filename])
+		}
+		if err != nil {
+			return
+		}
+		for _, name := range append([]string{filename}, links[filename]...) {
+			if err = report(name, sizes[name], sums, summary != nil && summary.hashedFiles[filename], sErr); err != nil {
+				cancel()
+				return
+			}
 		}
 	})
+	if prog != nil {
+		prog.Stop()
+	}
+
+	for _, url := range urls {
+		if err != nil || ctx.Err() != nil {
+			break
+		}
+		size, sums, sErr := hashURL(ctx, srv, url)
+		if sErr != nil && ctx.Err() != nil {
+			break
+		}
+		if summary != nil && sErr == nil {
+			summary.onHashed(url, xsum.HashStats{Bytes: size})
+		}
+		err = report(url, size, sums, true, sErr)
+	}
 
 	return
 }
 
 func main() {
+	if err := loadDefaults(flag.CommandLine, configFile, os.Getenv); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 	flag.Parse()
+	if nulFlag {
+		outputFlag = "nul"
+	}
+	if vvFlag {
+		verboseFlag = true
+	}
 	if helpFlag {
 		flag.Usage()
 		os.Exit(0)
@@ -119,15 +629,173 @@ func main() {
 		os.Exit(0)
 	}
 
-	if flag.NArg() == 0 {
-		flag.Usage()
-		os.Exit(2)
+	if syslogFlag {
+		var err error
+		if sysLog, err = openSyslog(); err != nil {
+			fmt.Fprintf(os.Stderr, "-syslog: %s\n", err)
+			os.Exit(2)
+		}
+		defer sysLog.Close()
 	}
 
+	// The first signal cancels the run so output is flushed and closed; a
+	// second one kills the process as usual.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	if benchFlag {
+		if err := doBench(ctx, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if gitRootFlag {
+		if baseDirFlag != "" {
+			fmt.Fprintln(os.Stderr, "-relative-to-git-root and -base-dir are mutually exclusive")
+			os.Exit(2)
+		}
+		var err error
+		if baseDirFlag, err = gitBaseDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "-relative-to-git-root: %s\n", err)
+			os.Exit(1)
+		}
+	} else if gitFallbackFlag {
+		fmt.Fprintln(os.Stderr, "-git-root-fallback requires -relative-to-git-root")
+		os.Exit(2)
+	}
+
+	if baseDirFlag != "" {
+		var err error
+		if baseDirFlag, err = filepath.Abs(baseDirFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if followLinksFlag && !recursiveFlag {
+		fmt.Fprintln(os.Stderr, "-L requires -r")
+		os.Exit(2)
+	}
+
+	if shortFlag != 0 && (checkFlag != "" || auditFlag != "" || expectFlag != "") {
+		fmt.Fprintln(os.Stderr, "-short is display only and cannot be used with -check, -audit or -expect")
+		os.Exit(2)
+	}
+
+	if verifySigFlag != "" && checkFlag == "" {
+		fmt.Fprintln(os.Stderr, "-verify-sig requires -check")
+		os.Exit(2)
+	}
+
+	if checkFlag != "" {
+		if flag.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, "-check takes no file arguments")
+			os.Exit(2)
+		}
+		failed, err := doCheck(ctx, checkFlag)
+		exitIfInterrupted(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if diffFlag {
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "-diff takes exactly two manifests")
+			os.Exit(2)
+		}
+		differ, err := doDiff(os.Stdout, flag.Arg(0), flag.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if differ {
+			os.Exit(1)
+		}
+		return
+	}
+
+	args, err := inputFiles(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if auditFlag != "" {
+		drift, err := doAudit(ctx, os.Stdout, auditFlag, args)
+		exitIfInterrupted(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if drift {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if expectFlag != "" {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "-expect takes exactly one file")
+			os.Exit(2)
+		}
+		match, err := doExpect(ctx, expectFlag, args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !match {
+			os.Exit(1)
+		}
+		return
+	}
 
-	if err := doXsum(ctx, flag.Args(), strings.Split(algorithmFlag, ",")); err != nil {
+	if treeFlag {
+		err := doTree(ctx, os.Stdout, args)
+		exitIfInterrupted(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	algorithms := strings.Split(algorithmFlag, ",")
+	warnWeak(os.Stderr, algorithms)
+	if verboseFlag {
+		reportCPU(os.Stderr, algorithms)
+	}
+
+	if chunkFlag != "" {
+		avg, err := parseSize(chunkFlag)
+		if err != nil || avg < 4*chunkWindow || avg > maxChunkAvg {
+			fmt.Fprintf(os.Stderr, "invalid -chunk %s\n", chunkFlag)
+			os.Exit(2)
+		}
+		err = doChunk(ctx, args, algorithms, int(avg))
+		exitIfInterrupted(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = doXsum(ctx, args, algorithms)
+	exitIfInterrupted(ctx)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
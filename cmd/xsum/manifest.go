@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// manifestEntry is one file read back from a CSV or JSON manifest.
+type manifestEntry struct {
+	filename string
+	sums     map[string][]byte
+}
+
+// manifest is the parsed content of a previously written xsum output.
+type manifest struct {
+	algorithms []string
+	entries    []manifestEntry
+	// failed lists the files whose rows recorded an error instead of sums.
+	// They are left out of entries.
+	failed []string
+	// malformed describes rows that could not be parsed, e.g. truncated
+	// lines or invalid sums. They are left out of entries.
+	malformed []string
+}
+
+// readManifest reads a manifest written by the csv or json writer, with or
+// without -envelope.
+// Sums are decoded with decode. Rows that recorded an error are listed in
+// failed, and rows that cannot be parsed in malformed.
+// Relative filenames are resolved against -base-dir, or else the working directory.
+// Gzip-compressed manifests, as written with -compress, are decompressed.
+func readManifest(filename string, decode sumDecoder) (*manifest, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(filename, b, decode)
+}
+
+// parseManifest parses the content b of the manifest filename, as readManifest.
+func parseManifest(filename string, b []byte, decode sumDecoder) (m *manifest, err error) {
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if b, err = io.ReadAll(gz); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	var envelope jsonEnvelope
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) && json.Unmarshal(b, &envelope) == nil && envelope.Files != nil {
+		m, err = envelope.manifest(decode)
+	} else if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		m, err = parseJSONManifest(bytes.NewReader(b), decode)
+	} else {
+		m, err = parseCSVManifest(bytes.NewReader(b), decode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for i := range m.entries {
+		if m.entries[i].filename, err = resolveManifestName(m.entries[i].filename); err != nil {
+			return nil, err
+		}
+	}
+	for i := range m.failed {
+		if m.failed[i], err = resolveManifestName(m.failed[i]); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// resolveManifestName makes a filename read from a manifest absolute,
+// relative to -base-dir when it is set.
+func resolveManifestName(filename string) (string, error) {
+	if baseDirFlag != "" && !filepath.IsAbs(filename) {
+		filename = filepath.Join(baseDirFlag, filename)
+	}
+	return filepath.Abs(filename)
+}
+
+// algorithmColumn returns the algorithm name for a "<algorithm>sum" column.
+func algorithmColumn(column string) (string, bool) {
+	algorithm, ok := strings.CutSuffix(column, "sum")
+	return algorithm, ok && algorithm != ""
+}
+
+func parseCSVManifest(r io.Reader, decode sumDecoder) (*manifest, error) {
+	br := bufio.NewReader(r)
+	if first, _ := br.Peek(1); len(first) == 1 && first[0] == '#' {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if version, ok := parseCSVVersion(strings.TrimSpace(line)); ok {
+			if err = checkSchemaVersion(version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(headers))
+	m := &manifest{}
+	for i, header := range headers {
+		columns[header] = i
+		if algorithm, ok := algorithmColumn(header); ok {
+			m.algorithms = append(m.algorithms, algorithm)
+		}
+	}
+	filenameCol, ok := columns["filename"]
+	if !ok {
+		return nil, errors.New("missing filename column")
+	}
+	errorCol, hasErrorCol := columns["error"]
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if parseErr, ok := errors.AsType[*csv.ParseError](err); ok {
+			m.malformed = append(m.malformed, parseErr.Error())
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) == 1 && strings.HasPrefix(record[0], csvSummaryPrefix) {
+			continue
+		}
+		if len(record) != len(headers) {
+			m.malformed = append(m.malformed, fmt.Sprintf("line %d: expected %d fields, got %d", line, len(headers), len(record)))
+			continue
+		}
+		if hasErrorCol && record[errorCol] != "" {
+			m.failed = append(m.failed, record[filenameCol])
+			continue
+		}
+		entry := manifestEntry{filename: record[filenameCol], sums: make(map[string][]byte)}
+		valid := true
+		for _, algorithm := range m.algorithms {
+			value := record[columns[algorithm+"sum"]]
+			if value == "" {
+				continue
+			}
+			if entry.sums[algorithm], err = decode(value); err != nil {
+				m.malformed = append(m.malformed, fmt.Sprintf("line %d: %s: invalid %s sum: %v", line, entry.filename, algorithm, err))
+				valid = false
+				break
+			}
+		}
+		if valid {
+			m.entries = append(m.entries, entry)
+		}
+	}
+	return m, nil
+}
+
+func parseJSONManifest(r io.Reader, decode sumDecoder) (*manifest, error) {
+	m := &manifest{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record map[string]any
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err == nil {
+			err = m.addJSONRecord(record, decode)
+		}
+		if err != nil {
+			m.malformed = append(m.malformed, fmt.Sprintf("line %d: %v", line, err))
+		}
+	}
+	slices.Sort(m.algorithms)
+	return m, scanner.Err()
+}
+
+// jsonEnvelope is the document written by the json writer with -envelope.
+type jsonEnvelope struct {
+	Version int              `json:"version"`
+	Files   []map[string]any `json:"files"`
+}
+
+func (e *jsonEnvelope) manifest(decode sumDecoder) (*manifest, error) {
+	if err := checkSchemaVersion(e.Version); err != nil {
+		return nil, err
+	}
+	m := &manifest{}
+	for i, record := range e.Files {
+		if err := m.addJSONRecord(record, decode); err != nil {
+			m.malformed = append(m.malformed, fmt.Sprintf("file %d: %v", i, err))
+		}
+	}
+	slices.Sort(m.algorithms)
+	return m, nil
+}
+
+// addJSONRecord adds one JSON file record to m, skipping records that
+// recorded an error and the trailing -summary record.
+func (m *manifest) addJSONRecord(record map[string]any, decode sumDecoder) error {
+	if summary, _ := record["summary"].(bool); summary {
+		return nil
+	}
+	filename, ok := record["filename"].(string)
+	if !ok {
+		return errors.New("missing filename")
+	}
+	if e, _ := record["error"].(string); e != "" {
+		m.failed = append(m.failed, filename)
+		return nil
+	}
+	entry := manifestEntry{filename: filename, sums: make(map[string][]byte)}
+	for key, value := range record {
+		algorithm, ok := algorithmColumn(key)
+		if !ok {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s is not a string", key)
+		}
+		var err error
+		if entry.sums[algorithm], err = decode(s); err != nil {
+			return fmt.Errorf("invalid %s sum: %w", algorithm, err)
+		}
+		if !slices.Contains(m.algorithms, algorithm) {
+			m.algorithms = append(m.algorithms, algorithm)
+		}
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisigSuffix is appended to a manifest's name to find its signature, as
+// minisign -S writes it.
+const minisigSuffix = ".minisig"
+
+// minisign signature algorithms: "Ed" signs the file itself, "ED" (the
+// default since minisign 0.10) signs its BLAKE2b-512 hash.
+const (
+	minisignLegacy    = "Ed"
+	minisignPrehashed = "ED"
+)
+
+var errBadSignature = errors.New("signature verification failed")
+
+// minisignKey is an Ed25519 public key in minisign format.
+type minisignKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// parseMinisignKey accepts either a minisign public key as printed by
+// minisign -G ("RWQ...") or the path of a minisign .pub file.
+func parseMinisignKey(value string) (*minisignKey, error) {
+	encoded := value
+	if b, err := os.ReadFile(value); err == nil {
+		encoded = ""
+		for line := range strings.Lines(string(b)) {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+				encoded = line
+				break
+			}
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != minisignLegacy {
+		return nil, fmt.Errorf("invalid minisign public key %q", value)
+	}
+	k := &minisignKey{key: ed25519.PublicKey(raw[10:])}
+	copy(k.id[:], raw[2:10])
+	return k, nil
+}
+
+// verify checks a minisign signature file over content: the signature of the
+// content (or its BLAKE2b-512 hash) and the global signature covering the
+// trusted comment.
+func (k *minisignKey) verify(content []byte, sigFile []byte) error {
+	lines := strings.Split(strings.TrimRight(string(sigFile), "\r\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return errors.New("malformed signature file")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !bytes.Equal(sig[2:10], k.id[:]) {
+		return fmt.Errorf("signed by key %X, not %X", sig[2:10], k.id)
+	}
+	message := content
+	switch string(sig[:2]) {
+	case minisignLegacy:
+	case minisignPrehashed:
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", sig[:2])
+	}
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return errBadSignature
+	}
+
+	trusted, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return errors.New("malformed trusted comment")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("malformed global signature")
+	}
+	if !ed25519.Verify(k.key, append(sig[10:len(sig):len(sig)], trusted...), global) {
+		return fmt.Errorf("trusted comment: %w", errBadSignature)
+	}
+	return nil
+}
+
+// readSignedManifest reads filename and verifies it against filename.minisig
+// with key before parsing, so the bytes parsed are the bytes verified.
+func readSignedManifest(filename string, key *minisignKey, decode sumDecoder) (*manifest, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	sigFile, err := os.ReadFile(filename + minisigSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if err = key.verify(content, sigFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename+minisigSuffix, err)
+	}
+	return parseManifest(filename, content, decode)
+}
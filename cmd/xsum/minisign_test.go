@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignFixture signs content in minisign's format with a fresh key and
+// returns the public key string and the signature file.
+func minisignFixture(t *testing.T, content []byte, algorithm string, trusted string) (string, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pubKey := base64.StdEncoding.EncodeToString(append(append([]byte(minisignLegacy), keyID...), pub...))
+
+	message := content
+	if algorithm == minisignPrehashed {
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	}
+	sig := ed25519.Sign(priv, message)
+	global := ed25519.Sign(priv, append(sig, trusted...))
+	sigFile := fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), keyID...), sig...)),
+		trusted,
+		base64.StdEncoding.EncodeToString(global))
+	return pubKey, []byte(sigFile)
+}
+
+func TestMinisignVerify(t *testing.T) {
+	content := []byte("hostname,filename,size,error,sha256sum\n")
+	for _, algorithm := range []string{minisignLegacy, minisignPrehashed} {
+		pubKey, sigFile := minisignFixture(t, content, algorithm, "timestamp:1 file:manifest.csv")
+		key, err := parseMinisignKey(pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = key.verify(content, sigFile); err != nil {
+			t.Errorf("%s: valid signature rejected: %v", algorithm, err)
+		}
+		if err = key.verify(append(content, "x"...), sigFile); !errors.Is(err, errBadSignature) {
+			t.Errorf("%s: tampered manifest: got %v, want %v", algorithm, err, errBadSignature)
+		}
+
+		otherKey, _ := minisignFixture(t, content, algorithm, "")
+		other, err := parseMinisignKey(otherKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = other.verify(content, sigFile); !errors.Is(err, errBadSignature) {
+			t.Errorf("%s: wrong key: got %v, want %v", algorithm, err, errBadSignature)
+		}
+	}
+
+	pubKey, sigFile := minisignFixture(t, content, minisignPrehashed, "timestamp:1")
+	key, _ := parseMinisignKey(pubKey)
+	forged := strings.Replace(string(sigFile), "timestamp:1", "timestamp:2", 1)
+	if err := key.verify(content, []byte(forged)); !errors.Is(err, errBadSignature) {
+		t.Errorf("tampered trusted comment: got %v, want %v", err, errBadSignature)
+	}
+}
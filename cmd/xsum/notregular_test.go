@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDoXsumNotRegular(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no device files to hash")
+	}
+	defer func(format, out string, cache bool) {
+		outputFlag, outFileFlag, cacheFlag = format, out, cache
+	}(outputFlag, outFileFlag, cacheFlag)
+	dir := t.TempDir()
+	outputFlag, outFileFlag, cacheFlag = "csv", filepath.Join(dir, "out.csv"), false
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileLink := filepath.Join(dir, "file-link")
+	devLink := filepath.Join(dir, "dev-link")
+	if err := os.Symlink(file, fileLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(os.DevNull, devLink); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed files are reported after the output is written.
+	if err := doXsum(context.Background(), []string{os.DevNull, devLink, fileLink}, []string{"sha256"}); err == nil {
+		t.Fatal("expected doXsum to report the failed files")
+	}
+	b, err := os.ReadFile(outFileFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, want := range []string{
+		"not a regular file (character device)",
+		"not a regular file (symlink to character device)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	// A link to a regular file is hashed as its target.
+	if !strings.Contains(out, "file-link,4,,") {
+		t.Errorf("expected the link to a file to be hashed:\n%s", out)
+	}
+}
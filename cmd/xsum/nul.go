@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// nulWriter emits NUL-terminated records for xargs -0 style consumers: the sum
+// for each algorithm followed by the filename, every field ending in a NUL
+// byte. Rows that failed have no sums, so their error goes to stderr instead.
+type nulWriter struct {
+	w          *bufio.Writer
+	algorithms []string
+	encode     sumEncoder
+}
+
+func newNulWriter(w io.Writer, cfg writerConfig) *nulWriter {
+	return &nulWriter{w: bufio.NewWriter(w), algorithms: cfg.algorithms, encode: cfg.encode}
+}
+
+// Close implements xsumWriter.
+func (w *nulWriter) Close() error {
+	return w.w.Flush()
+}
+
+// Write implements xsumWriter.
+func (w *nulWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return nil
+	}
+	for _, algorithm := range w.algorithms {
+		w.w.WriteString(w.encode(sums[algorithm]))
+		w.w.WriteByte(0)
+	}
+	w.w.WriteString(filename)
+	return w.w.WriteByte(0)
+}
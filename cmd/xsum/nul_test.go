@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoXsumNul(t *testing.T) {
+	defer func(format, out string, cache bool) {
+		outputFlag, outFileFlag, cacheFlag = format, out, cache
+	}(outputFlag, outFileFlag, cacheFlag)
+	dir := t.TempDir()
+	outputFlag, outFileFlag, cacheFlag = "nul", filepath.Join(dir, "out"), false
+
+	// A newline in the name is why the output is NUL-terminated.
+	file := filepath.Join(dir, "two\nlines")
+	if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := doXsum(context.Background(), []string{file}, []string{"sha256", "md5"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(outFileFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md5sum := md5.Sum([]byte("data"))
+	sha256sum := sha256.Sum256([]byte("data"))
+	want := hex.EncodeToString(md5sum[:]) + "\x00" + hex.EncodeToString(sha256sum[:]) + "\x00" + file + "\x00"
+	if string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"maps"
+	"slices"
+)
+
+// orderedWriter writes rows to the wrapped writer in the order their
+// filenames were given, for -ordered. Each name is ranked by expect as the
+// inputs are read; a row is held only until every earlier name has been
+// written, so a slow file delays the rows after it but memory stays bounded
+// by how far workers run ahead. A row for a name that was never expected
+// (such as an error found while reading the inputs) is ranked when written.
+type orderedWriter struct {
+	next     xsumWriter
+	ranks    map[string]int
+	pending  map[int]row
+	assigned int
+	written  int
+}
+
+func newOrderedWriter(next xsumWriter) *orderedWriter {
+	return &orderedWriter{next: next, ranks: make(map[string]int), pending: make(map[int]row)}
+}
+
+// expect gives filename the next place in the output.
+func (w *orderedWriter) expect(filename string) {
+	if _, ok := w.ranks[filename]; !ok {
+		w.ranks[filename] = w.assigned
+		w.assigned++
+	}
+}
+
+// Write implements xsumWriter.
+func (w *orderedWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	w.expect(filename)
+	w.pending[w.ranks[filename]] = row{hostname, filename, size, sums, err}
+	for {
+		r, ok := w.pending[w.written]
+		if !ok {
+			return nil
+		}
+		delete(w.pending, w.written)
+		w.written++
+		if err := w.next.Write(r.hostname, r.filename, r.size, r.sums, r.err); err != nil {
+			return err
+		}
+	}
+}
+
+// Close implements xsumWriter. Rows still waiting on a name that was never
+// written, as after an interruption, are written in order first.
+func (w *orderedWriter) Close() error {
+	for _, rank := range slices.Sorted(maps.Keys(w.pending)) {
+		r := w.pending[rank]
+		if err := w.next.Write(r.hostname, r.filename, r.size, r.sums, r.err); err != nil {
+			w.next.Close()
+			return err
+		}
+	}
+	w.pending = nil
+	return w.next.Close()
+}
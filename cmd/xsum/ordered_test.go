@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// nameWriter records the filenames written to it.
+type nameWriter struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (w *nameWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.names = append(w.names, filename)
+	return nil
+}
+
+func (w *nameWriter) written() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.names)
+}
+
+func (w *nameWriter) Close() error { return nil }
+
+func TestOrderedWriter(t *testing.T) {
+	next := &nameWriter{}
+	w := newOrderedWriter(next)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		w.expect(name)
+	}
+	w.Write("h", "c", 0, nil, nil)
+	w.Write("h", "a", 0, nil, nil)
+	if want := []string{"a"}; !slices.Equal(next.names, want) {
+		t.Fatalf("after c and a got %v, want %v", next.names, want)
+	}
+	w.Write("h", "b", 0, nil, nil)
+	if want := []string{"a", "b", "c"}; !slices.Equal(next.names, want) {
+		t.Fatalf("after b got %v, want %v", next.names, want)
+	}
+	// e was never expected, and d is never written, as after an interrupt.
+	w.Write("h", "e", 0, nil, nil)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "e"}; !slices.Equal(next.names, want) {
+		t.Fatalf("after Close got %v, want %v", next.names, want)
+	}
+}
+
+func TestOrderedWriterRecursive(t *testing.T) {
+	defer func(format string, ordered, recursive, cache bool) {
+		outputFlag, orderedFlag, recursiveFlag, cacheFlag = format, ordered, recursive, cache
+	}(outputFlag, orderedFlag, recursiveFlag, cacheFlag)
+	next := &nameWriter{}
+	writers["names"] = func(io.Writer, writerConfig) xsumWriter { return next }
+	defer delete(writers, "names")
+	outputFlag, orderedFlag, recursiveFlag, cacheFlag = "names", true, true, false
+
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// URLs are hashed after every file, so by the time the server is asked
+	// for one both file rows should have been written, not held until Close.
+	writtenAtURL := -1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writtenAtURL = next.written()
+		io.WriteString(w, "url")
+	}))
+	defer srv.Close()
+
+	if err := doXsum(context.Background(), []string{dir, srv.URL}, []string{"sha256"}); err != nil {
+		t.Fatal(err)
+	}
+	if writtenAtURL != 2 {
+		t.Errorf("%d rows written before the URL was hashed, want 2", writtenAtURL)
+	}
+	if len(next.names) != 3 || next.names[2] != srv.URL {
+		t.Errorf("got rows %q", next.names)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// output is where rows are written: stdout or the -o file, optionally
+// gzip-compressed.
+type output struct {
+	io.Writer
+	closers []io.Closer
+}
+
+// openOutput opens filename for writing, or stdout if it is empty. The output
+// is gzip-compressed when compress is set or filename ends in ".gz".
+func openOutput(filename string, compress bool) (*output, error) {
+	out := &output{Writer: os.Stdout}
+	if filename != "" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return nil, err
+		}
+		out.Writer = f
+		out.closers = append(out.closers, f)
+	}
+	if compress || strings.HasSuffix(filename, ".gz") {
+		gz := gzip.NewWriter(out.Writer)
+		out.Writer = gz
+		out.closers = append(out.closers, gz)
+	}
+	return out, nil
+}
+
+// Close flushes the gzip stream, if any, and closes the file.
+func (o *output) Close() (err error) {
+	for i := len(o.closers) - 1; i >= 0; i-- {
+		if cErr := o.closers[i].Close(); err == nil {
+			err = cErr
+		}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressedOutputRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		filename string
+		compress bool
+	}{
+		{"manifest.csv", true},
+		{"manifest.json", true},
+		{"manifest.csv.gz", false},
+	} {
+		filename := filepath.Join(dir, tc.filename)
+		out, err := openOutput(filename, tc.compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		format := "csv"
+		if filepath.Ext(tc.filename) == ".json" {
+			format = "json"
+		}
+		w := writers[format](out, writerConfig{algorithms: []string{"sha256"}, encode: encoders["hex"]})
+		if err := w.Write("host", "/a", 1, map[string][]byte{"sha256": {0xab}}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+			t.Fatalf("%s: output is not gzip-compressed: %q", tc.filename, b)
+		}
+		m, err := readManifest(filename, decoders["hex"])
+		if err != nil {
+			t.Fatalf("%s: %v", tc.filename, err)
+		}
+		if len(m.entries) != 1 || m.entries[0].filename != "/a" || !bytes.Equal(m.entries[0].sums["sha256"], []byte{0xab}) {
+			t.Errorf("%s: unexpected entries %+v", tc.filename, m.entries)
+		}
+	}
+}
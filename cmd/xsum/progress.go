@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often -progress redraws its status line.
+const progressInterval = 500 * time.Millisecond
+
+// progress draws a status line on stderr for -progress with the files and
+// bytes done out of the totals known before hashing starts, and the
+// throughput so far. The line is redrawn in place with a carriage return.
+type progress struct {
+	w          io.Writer
+	totalFiles int
+	totalBytes int64
+	files      atomic.Int64
+	bytes      atomic.Int64
+	start      time.Time
+	width      int
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// startProgress starts redrawing the status line every progressInterval
+// until Stop is called.
+func startProgress(w io.Writer, totalFiles int, totalBytes int64) *progress {
+	p := &progress{
+		w:          w,
+		totalFiles: totalFiles,
+		totalBytes: totalBytes,
+		start:      time.Now(),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.draw("")
+			}
+		}
+	}()
+	return p
+}
+
+// add records a finished file of size bytes, hashed or not.
+func (p *progress) add(size int64) {
+	p.files.Add(1)
+	p.bytes.Add(size)
+}
+
+// draw writes the status line over the previous one, padded to cover it.
+func (p *progress) draw(end string) {
+	done := p.bytes.Load()
+	percent := 100.0
+	if p.totalBytes > 0 {
+		percent = float64(done) * 100 / float64(p.totalBytes)
+	}
+	rate := float64(done) / max(time.Since(p.start).Seconds(), 1e-9)
+	line := fmt.Sprintf("%d/%d files, %s of %s (%.0f%%), %s/s",
+		p.files.Load(), p.totalFiles, formatSize(done), formatSize(p.totalBytes), percent, formatSize(int64(rate)))
+	pad := max(p.width-len(line), 0)
+	p.width = len(line)
+	fmt.Fprintf(p.w, "\r%s%s%s", line, strings.Repeat(" ", pad), end)
+}
+
+// Stop stops redrawing and leaves the final status on its own line.
+func (p *progress) Stop() {
+	close(p.stop)
+	<-p.done
+	p.draw("\n")
+}
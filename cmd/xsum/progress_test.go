@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProgressFinalLine(t *testing.T) {
+	var out strings.Builder
+	p := startProgress(&out, 2, 3<<20)
+	p.add(1 << 20)
+	p.add(2 << 20)
+	p.Stop()
+
+	line := out.String()
+	if !strings.HasPrefix(line, "\r2/2 files, 3.0 MiB of 3.0 MiB (100%), ") || !strings.HasSuffix(line, "/s\n") {
+		t.Fatalf("unexpected final line %q", line)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// defaultMaxOpenFiles has no limit to go by on this platform.
+func defaultMaxOpenFiles() int { return 0 }
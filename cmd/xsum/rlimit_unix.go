@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// defaultMaxOpenFiles returns half of the soft open file limit, leaving the
+// rest for stdio, the output file and anything else the process holds open.
+func defaultMaxOpenFiles() int {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0
+	}
+	return int(max(min(rlim.Cur/2, 4096), 1))
+}
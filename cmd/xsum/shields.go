@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// shieldsShortLen is the number of encoded characters shown in the badge message.
+const shieldsShortLen = 12
+
+// shieldsWriter emits a shields.io endpoint badge for a single file.
+// See https://shields.io/badges/endpoint-badge for the schema.
+type shieldsWriter struct {
+	w         io.Writer
+	algorithm string
+	encode    sumEncoder
+	badge     map[string]any
+	multiple  bool
+}
+
+func newShieldsWriter(w io.Writer, cfg writerConfig) *shieldsWriter {
+	return &shieldsWriter{w: w, algorithm: cfg.algorithms[0], encode: cfg.encode}
+}
+
+// Close implements xsumWriter.
+func (w *shieldsWriter) Close() error {
+	if w.badge == nil || w.multiple {
+		return nil
+	}
+	return json.NewEncoder(w.w).Encode(w.badge)
+}
+
+// Write implements xsumWriter.
+func (w *shieldsWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	if w.badge != nil {
+		w.multiple = true
+		return errors.New("shields format only supports a single file")
+	}
+	w.badge = map[string]any{
+		"schemaVersion": 1,
+		"label":         w.algorithm,
+	}
+	sum, ok := sums[w.algorithm]
+	if err != nil || !ok {
+		w.badge["message"] = "error"
+		w.badge["color"] = "red"
+		return nil
+	}
+	message := w.encode(sum)
+	if len(message) > shieldsShortLen {
+		message = message[:shieldsShortLen]
+	}
+	w.badge["message"] = message
+	w.badge["color"] = "green"
+	return nil
+}
+
+var _ xsumWriter = new(shieldsWriter)
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoXsumShields(t *testing.T) {
+	defer func(format, out string, cache bool) {
+		outputFlag, outFileFlag, cacheFlag = format, out, cache
+	}(outputFlag, outFileFlag, cacheFlag)
+	dir := t.TempDir()
+	outputFlag, outFileFlag, cacheFlag = "shields", filepath.Join(dir, "badge.json"), false
+
+	var files []string
+	for _, name := range []string{"a", "b"} {
+		files = append(files, filepath.Join(dir, name))
+		if err := os.WriteFile(files[len(files)-1], []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two files are refused before either is hashed, so no badge is written.
+	err := doXsum(context.Background(), files, []string{"sha256"})
+	if err == nil || !strings.Contains(err.Error(), "exactly one file") {
+		t.Fatalf("expected two files to be refused, got %v", err)
+	}
+	if b, _ := os.ReadFile(outFileFlag); len(b) != 0 {
+		t.Errorf("expected no badge, got %s", b)
+	}
+
+	if err = doXsum(context.Background(), files[:1], []string{"sha256"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(outFileFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256("a") starts with ca978112ca1b.
+	if want := `{"color":"green","label":"sha256","message":"ca978112ca1b","schemaVersion":1}` + "\n"; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
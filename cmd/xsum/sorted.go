@@ -0,0 +1,46 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// row is one buffered xsumWriter.Write call.
+type row struct {
+	hostname string
+	filename string
+	size     int64
+	sums     map[string][]byte
+	err      error
+}
+
+// sortedWriter holds every row in memory and writes them to the wrapped
+// writer ordered by filename on Close, so output does not depend on the
+// order workers finish in.
+type sortedWriter struct {
+	next xsumWriter
+	rows []row
+}
+
+func newSortedWriter(next xsumWriter) *sortedWriter {
+	return &sortedWriter{next: next}
+}
+
+// Write implements xsumWriter.
+func (w *sortedWriter) Write(hostname string, filename string, size int64, sums map[string][]byte, err error) error {
+	w.rows = append(w.rows, row{hostname, filename, size, sums, err})
+	return nil
+}
+
+// Close implements xsumWriter.
+func (w *sortedWriter) Close() error {
+	slices.SortStableFunc(w.rows, func(a, b row) int { return cmp.Compare(a.filename, b.filename) })
+	for _, r := range w.rows {
+		if err := w.next.Write(r.hostname, r.filename, r.size, r.sums, r.err); err != nil {
+			w.next.Close()
+			return err
+		}
+	}
+	w.rows = nil
+	return w.next.Close()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortedWriter(t *testing.T) {
+	next := &nameWriter{}
+	w := newSortedWriter(next)
+	for _, name := range []string{"/c", "/a/b", "/b", "/a"} {
+		w.Write("h", name, 0, nil, nil)
+	}
+	if len(next.names) != 0 {
+		t.Fatalf("rows written before Close: %v", next.names)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/a", "/a/b", "/b", "/c"}; !slices.Equal(next.names, want) {
+		t.Fatalf("after Close got %v, want %v", next.names, want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"slices"
+
+	"golang.org/x/term"
+)
+
+// stdinArg stands for the list of files read from standard input.
+const stdinArg = "-"
+
+// readFileList reads filenames separated by sep, skipping empty entries.
+// Names are otherwise taken verbatim, including surrounding spaces.
+func readFileList(r io.Reader, sep byte) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, sep); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	var filenames []string
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			filenames = append(filenames, scanner.Text())
+		}
+	}
+	return filenames, scanner.Err()
+}
+
+// inputFiles returns the file arguments with "-" replaced by the list read
+// from stdin. The list is also read when -stdin or -0 is given, or when
+// plainly hashing files with no arguments and stdin is not a terminal, as in
+// find . -type f | xsum. Other modes such as -expect or -audit never read
+// stdin unasked, so they do not wait on an open pipe.
+// Entries are separated by newlines, or by NUL with -0.
+func inputFiles(args []string) ([]string, error) {
+	plain := auditFlag == "" && expectFlag == "" && !treeFlag && chunkFlag == ""
+	fromStdin := stdinFlag || nulInFlag || slices.Contains(args, stdinArg) ||
+		(len(args) == 0 && plain && !term.IsTerminal(int(os.Stdin.Fd())))
+	if !fromStdin {
+		return args, nil
+	}
+	sep := byte('\n')
+	if nulInFlag {
+		sep = 0
+	}
+	list, err := readFileList(os.Stdin, sep)
+	if err != nil {
+		return nil, err
+	}
+	filenames := slices.DeleteFunc(slices.Clone(args), func(arg string) bool { return arg == stdinArg })
+	if i := slices.Index(args, stdinArg); i >= 0 {
+		return slices.Insert(filenames, min(i, len(filenames)), list...), nil
+	}
+	return append(filenames, list...), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadFileList(t *testing.T) {
+	got, err := readFileList(strings.NewReader("a\n\n b \nlast"), '\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", " b ", "last"}; !slices.Equal(got, want) {
+		t.Errorf("newline list: got %q, want %q", got, want)
+	}
+
+	got, err = readFileList(strings.NewReader("new\nline\x00b\x00"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"new\nline", "b"}; !slices.Equal(got, want) {
+		t.Errorf("NUL list: got %q, want %q", got, want)
+	}
+}
+
+func TestInputFilesAutoStdin(t *testing.T) {
+	defer func(stdin *os.File, expect string) { os.Stdin, expectFlag = stdin, expect }(os.Stdin, expectFlag)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	os.Stdin = r
+
+	// -expect does not read the pipe; if it did, it would block until the
+	// writer is closed.
+	expectFlag = "00"
+	if got, err := inputFiles(nil); err != nil || got != nil {
+		t.Fatalf("-expect: got %q, %v, want no files", got, err)
+	}
+
+	expectFlag = ""
+	w.WriteString("a\nb\n")
+	w.Close()
+	if got, err := inputFiles(nil); err != nil || !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("plain hashing: got %q, %v", got, err)
+	}
+}
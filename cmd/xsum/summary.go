@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// csvSummaryPrefix starts the trailing line written by -summary in CSV output.
+const csvSummaryPrefix = "# xsum-summary:"
+
+// outputSummary tallies the rows a csv or json writer has written, for the
+// trailing record -summary adds when the writer is closed.
+type outputSummary struct {
+	start time.Time
+	files int
+	bytes int64
+}
+
+func newOutputSummary() *outputSummary {
+	return &outputSummary{start: time.Now()}
+}
+
+// add counts one row, failed or not.
+func (s *outputSummary) add(size int64) {
+	s.files++
+	s.bytes += size
+}
+
+// record returns the fields of the trailing JSON object.
+func (s *outputSummary) record() map[string]any {
+	return map[string]any{
+		"summary":          true,
+		"files":            s.files,
+		"bytes":            s.bytes,
+		"duration_seconds": time.Since(s.start).Seconds(),
+	}
+}
+
+// csvLine returns the trailing CSV line, a comment that manifest readers skip.
+func (s *outputSummary) csvLine() string {
+	return fmt.Sprintf("%s files=%d bytes=%d duration_seconds=%.3f\n",
+		csvSummaryPrefix, s.files, s.bytes, time.Since(s.start).Seconds())
+}
+
+// runSummary is the run-level document written by -summary-json.
+type runSummary struct {
+	Files       int      `json:"files"`
+	Bytes       int64    `json:"bytes"`
+	Cached      int      `json:"cached"`
+	Hashed      int      `json:"hashed"`
+	Failed      int      `json:"failed"`
+	HashedBytes int64    `json:"hashed_bytes"`
+	Duration    float64  `json:"duration_seconds"`
+	Algorithms  []string `json:"algorithms"`
+	Throughput  float64  `json:"hashed_bytes_per_second"`
+
+	start       time.Time
+	hashedFiles map[string]bool
+}
+
+func newRunSummary(algorithms []string) *runSummary {
+	return &runSummary{
+		Algorithms:  algorithms,
+		start:       time.Now(),
+		hashedFiles: make(map[string]bool),
+	}
+}
+
+// onHashed records a file that was read rather than served from the cache.
+func (s *runSummary) onHashed(filename string, stats xsum.HashStats) {
+	s.hashedFiles[filename] = true
+	s.HashedBytes += stats.Bytes
+}
+
+// add counts one output row. hashed reports whether the sums were computed
+// in this run, for sources that bypass the cache such as URLs.
+func (s *runSummary) add(filename string, size int64, hashed bool, err error) {
+	s.Files++
+	s.Bytes += size
+	switch {
+	case err != nil:
+		s.Failed++
+	case hashed || s.hashedFiles[filename]:
+		s.Hashed++
+	default:
+		s.Cached++
+	}
+}
+
+// write finishes the summary and writes it to filename as indented JSON.
+// The throughput is of the whole run: the bytes read for hashing, by every
+// algorithm at once, over its wall-clock time.
+func (s *runSummary) write(filename string) error {
+	s.Duration = time.Since(s.start).Seconds()
+	s.Throughput = float64(s.HashedBytes) / max(s.Duration, 1e-9)
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o644)
+}
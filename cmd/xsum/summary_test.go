@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummaryRecord(t *testing.T) {
+	dir := t.TempDir()
+	for _, envelope := range []bool{false, true} {
+		for _, format := range []string{"csv", "json"} {
+			name := fmt.Sprintf("%s envelope=%v", format, envelope)
+			var buf bytes.Buffer
+			w := writers[format](&buf, writerConfig{algorithms: []string{"sha256"}, encode: encoders["hex"], envelope: envelope, summary: true})
+			if err := w.Write("host", "/a", 1, map[string][]byte{"sha256": {0xab}}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write("host", "/b", 2, nil, os.ErrNotExist); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			last := lines[len(lines)-1]
+			switch {
+			case format == "csv":
+				if !strings.HasPrefix(last, csvSummaryPrefix+" files=2 bytes=3 ") {
+					t.Errorf("%s: unexpected summary line %q", name, last)
+				}
+			case envelope:
+				var doc struct {
+					Summary struct{ Files, Bytes int } `json:"summary"`
+				}
+				if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || doc.Summary.Files != 2 || doc.Summary.Bytes != 3 {
+					t.Errorf("%s: unexpected summary in %s (%v)", name, buf.Bytes(), err)
+				}
+			default:
+				var record struct {
+					Summary      bool
+					Files, Bytes int
+				}
+				if err := json.Unmarshal([]byte(last), &record); err != nil || !record.Summary || record.Files != 2 || record.Bytes != 3 {
+					t.Errorf("%s: unexpected summary record %q (%v)", name, last, err)
+				}
+			}
+
+			// Manifest readers skip the summary.
+			filename := filepath.Join(dir, "manifest."+format)
+			if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := readManifest(filename, decoders["hex"])
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if len(m.entries) != 1 || len(m.malformed) != 0 {
+				t.Errorf("%s: expected one entry and no malformed rows, got %+v", name, m)
+			}
+		}
+	}
+}
+
+func TestSummaryJSONCountsURLBytes(t *testing.T) {
+	defer func(format, out, summary string, cache bool) {
+		outputFlag, outFileFlag, summaryFlag, cacheFlag = format, out, summary, cache
+	}(outputFlag, outFileFlag, summaryFlag, cacheFlag)
+	dir := t.TempDir()
+	outputFlag, outFileFlag, summaryFlag, cacheFlag = "csv", filepath.Join(dir, "out.csv"), filepath.Join(dir, "summary.json"), false
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("12345"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "123")
+	}))
+	defer srv.Close()
+
+	if err := doXsum(context.Background(), []string{file, srv.URL}, []string{"sha256"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(summaryFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		Hashed      int     `json:"hashed"`
+		HashedBytes int64   `json:"hashed_bytes"`
+		Throughput  float64 `json:"hashed_bytes_per_second"`
+	}
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Hashed != 2 || summary.HashedBytes != 8 || summary.Throughput <= 0 {
+		t.Errorf("unexpected summary %s", b)
+	}
+}
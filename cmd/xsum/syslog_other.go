@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package main
+
+import "errors"
+
+// openSyslog fails: there is no syslog on this platform.
+func openSyslog() (logger, error) {
+	return nil, errors.New("-syslog is not supported on this platform")
+}
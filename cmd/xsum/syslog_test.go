@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeLogger records messages as "level: message".
+type fakeLogger struct{ messages []string }
+
+func (l *fakeLogger) Err(m string) error     { return l.log("err", m) }
+func (l *fakeLogger) Warning(m string) error { return l.log("warning", m) }
+func (l *fakeLogger) Info(m string) error    { return l.log("info", m) }
+func (l *fakeLogger) Close() error           { return nil }
+
+func (l *fakeLogger) log(level, m string) error {
+	l.messages = append(l.messages, level+": "+m)
+	return nil
+}
+
+func TestDoXsumSyslog(t *testing.T) {
+	defer func(format, out string, cache bool, log logger) {
+		outputFlag, outFileFlag, cacheFlag, sysLog = format, out, cache, log
+	}(outputFlag, outFileFlag, cacheFlag, sysLog)
+	dir := t.TempDir()
+	outputFlag, outFileFlag, cacheFlag = "csv", filepath.Join(dir, "out.csv"), false
+
+	good := filepath.Join(dir, "good")
+	if err := os.WriteFile(good, []byte("good"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	fake := &fakeLogger{}
+	sysLog = fake
+	if err := doXsum(context.Background(), []string{good}, []string{"sha256"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"info: 1 files, 0 failed"}; !slices.Equal(fake.messages, want) {
+		t.Errorf("clean run logged %q, want %q", fake.messages, want)
+	}
+
+	fake = &fakeLogger{}
+	sysLog = fake
+	if err := doXsum(context.Background(), []string{good, missing}, []string{"sha256"}); err == nil {
+		t.Fatal("expected the missing file to fail the run")
+	}
+	if len(fake.messages) != 2 ||
+		!strings.HasPrefix(fake.messages[0], "err: "+missing+": ") ||
+		fake.messages[1] != "warning: 2 files, 1 failed" {
+		t.Errorf("failed run logged %q", fake.messages)
+	}
+}
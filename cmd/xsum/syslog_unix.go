@@ -0,0 +1,11 @@
+//go:build !windows && !plan9
+
+package main
+
+import "log/syslog"
+
+// openSyslog connects to the local syslog daemon, which journald also
+// listens on, logging as xsum under the daemon facility.
+func openSyslog() (logger, error) {
+	return syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "xsum")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ophymx/utils/attrutil"
+	"github.com/ophymx/utils/xsum"
+)
+
+// treeAttr is the attribute, within the cache namespace, holding the last
+// digest computed for a directory, and treeMtimeAttr the modification time
+// the directory had when it was walked for that digest.
+const (
+	treeAttr      = "tree"
+	treeMtimeAttr = "tree-mtime"
+)
+
+// treeRacyWindow is how recently before a walk a directory may have been
+// modified and still have its time stored with its digest. A change made
+// just after the walk could otherwise leave the directory with the same
+// time on filesystems with coarse timestamps and be pruned next time.
+const treeRacyWindow = 2 * time.Second
+
+// treeWalk is one walk of a tree for -tree.
+type treeWalk struct {
+	attrs attrutil.Attr
+	start time.Time
+	// files lists the regular files to hash.
+	files []string
+	// mtimes holds the modification time of each directory walked.
+	mtimes map[string]time.Time
+	// pruned holds the stored digest of each subdirectory that was not
+	// walked because its modification time matched the stored one.
+	pruned map[string][]byte
+}
+
+// walkTreeDigests walks root for -tree. With attrs, a subdirectory whose
+// modification time still matches the one stored with its digest is not
+// walked and the stored digest is used instead. A directory's time changes
+// when entries are added, removed or renamed in it, which covers editors
+// that save by renaming, but not files rewritten in place below an otherwise
+// untouched directory; -cache=false walks and hashes everything again.
+func walkTreeDigests(root string, attrs attrutil.Attr) (*treeWalk, error) {
+	w := &treeWalk{attrs: attrs, start: time.Now(), mtimes: make(map[string]time.Time), pruned: make(map[string][]byte)}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			w.files = append(w.files, path)
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		w.mtimes[path] = info.ModTime()
+		if path == root || attrs == nil {
+			return nil
+		}
+		if digest, ok := w.stored(path, info.ModTime()); ok {
+			w.pruned[path] = digest
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return w, err
+}
+
+// stored returns the digest stored on dir if it was stored for mtime.
+func (w *treeWalk) stored(dir string, mtime time.Time) ([]byte, bool) {
+	b, err := w.attrs.Get(dir, treeMtimeAttr)
+	if err != nil {
+		return nil, false
+	}
+	if stored, ok := timeFromBytes(b); !ok || !stored.Equal(mtime) {
+		return nil, false
+	}
+	digest, err := w.attrs.Get(dir, treeAttr)
+	return digest, err == nil && len(digest) > 0
+}
+
+// treeDigest computes a Merkle digest of dir from the sha256 of each file.
+// Every entry contributes its type, name and digest, in name order, so any
+// added, removed, renamed or modified file below dir changes the result.
+// Symlinks contribute their target; other file types are ignored.
+// Subdirectories pruned by the walk contribute their stored digest. The
+// digest of each directory walked is stored on it when the walk has attrs,
+// with its time from the walk unless that is within treeRacyWindow of it.
+func (w *treeWalk) treeDigest(dir string, sums map[string][]byte) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		var kind string
+		var digest []byte
+		switch entry.Type() {
+		case 0:
+			kind, digest = "f", sums[path]
+			if digest == nil {
+				return nil, fmt.Errorf("%s: no sum", path)
+			}
+		case fs.ModeDir:
+			kind = "d"
+			if pruned, ok := w.pruned[path]; ok {
+				digest = pruned
+			} else if digest, err = w.treeDigest(path, sums); err != nil {
+				return nil, err
+			}
+		case fs.ModeSymlink:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return nil, err
+			}
+			kind, digest = "l", []byte(target)
+		default:
+			continue
+		}
+		fmt.Fprintf(h, "%s %d:%s %d:", kind, len(entry.Name()), entry.Name(), len(digest))
+		h.Write(digest)
+	}
+	sum := h.Sum(nil)
+	if mtime, ok := w.mtimes[dir]; ok && w.attrs != nil {
+		// Best effort, like the file cache.
+		_ = w.attrs.Delete(dir, treeMtimeAttr)
+		if w.attrs.Set(dir, treeAttr, sum) == nil && mtime.Before(w.start.Add(-treeRacyWindow)) {
+			_ = w.attrs.Set(dir, treeMtimeAttr, timeToBytes(mtime))
+		}
+	}
+	return sum, nil
+}
+
+// doTree prints a Merkle digest for each directory in roots to w and whether
+// it changed since the digest last stored on the directory.
+// File sums come from the xattr cache where valid, so only modified files are
+// read again, and subdirectories unchanged since the last run are not walked.
+func doTree(ctx context.Context, w io.Writer, roots []string) error {
+	encode, ok := encoders[encodingFlag]
+	if !ok {
+		return fmt.Errorf("unknown encoding: %s", encodingFlag)
+	}
+	srv, err := xsum.NewServer("sha256")
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	var cache xsum.Cache
+	var attrs attrutil.Attr
+	if cacheFlag {
+		cache = newXattrCache(cacheNSFlag)
+		attrs = attrutil.Xattr().NS(cacheNSFlag)
+	}
+
+	for _, root := range roots {
+		if root, err = filepath.Abs(root); err != nil {
+			return err
+		}
+		walk, err := walkTreeDigests(root, attrs)
+		if err != nil {
+			return err
+		}
+		sums := make(map[string][]byte, len(walk.files))
+		var hashErr error
+		xsum.Parallel(ctx, srv, cache, walk.files, func(filename string, s map[string][]byte, err error) {
+			if err != nil && hashErr == nil {
+				hashErr = fmt.Errorf("%s: %w", filename, err)
+			}
+			sums[filename] = s["sha256"]
+		})
+		if hashErr != nil {
+			return hashErr
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		var previous []byte
+		if attrs != nil {
+			previous, _ = attrs.Get(root, treeAttr)
+		}
+		digest, err := walk.treeDigest(root, sums)
+		if err != nil {
+			return err
+		}
+		status := "changed"
+		if bytes.Equal(previous, digest) {
+			status = "unchanged"
+		}
+		fmt.Fprintf(w, "%s  %s  %s\n", encode(digest), root, status)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ophymx/utils/attrutil"
+)
+
+func TestTreeIncremental(t *testing.T) {
+	defer func(cache bool, ns string) { cacheFlag, cacheNSFlag = cache, ns }(cacheFlag, cacheNSFlag)
+	cacheFlag, cacheNSFlag = true, "user.xsum-test"
+	attrs := attrutil.Xattr().NS(cacheNSFlag)
+
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	for _, name := range []string{"a", "sub/b", "sub/deep/c"} {
+		filename := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := attrs.Set(root, "probe", []byte{1}); attrutil.IsNotSupported(err) {
+		t.Skipf("extended attributes not supported: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	// Directories modified just before a walk are never pruned.
+	past := time.Now().Add(-time.Hour)
+	for _, dir := range []string{root, sub, filepath.Join(sub, "deep")} {
+		if err := os.Chtimes(dir, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func() string {
+		t.Helper()
+		var out strings.Builder
+		if err := doTree(context.Background(), &out, []string{root}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	first := run()
+	if !strings.HasSuffix(first, "  changed\n") {
+		t.Fatalf("first run: %q", first)
+	}
+
+	walk, err := walkTreeDigests(root, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(root, "a")}; !slices.Equal(walk.files, want) {
+		t.Errorf("unchanged subtree was walked: files %q, want %q", walk.files, want)
+	}
+	if _, ok := walk.pruned[sub]; !ok {
+		t.Errorf("expected %s to be pruned, got %v", sub, walk.pruned)
+	}
+	if second := run(); second != strings.Replace(first, "  changed", "  unchanged", 1) {
+		t.Errorf("second run: %q", second)
+	}
+
+	// Adding a file changes the directory's time, so it is walked again.
+	if err := os.WriteFile(filepath.Join(sub, "new"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if walk, err = walkTreeDigests(root, attrs); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(walk.files, filepath.Join(sub, "new")) {
+		t.Errorf("changed subtree was not walked: files %q", walk.files)
+	}
+	if third := run(); !strings.HasSuffix(third, "  changed\n") || third == first {
+		t.Errorf("third run: %q", third)
+	}
+}
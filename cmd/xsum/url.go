@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ophymx/utils/xsum"
+)
+
+// isURL reports whether name should be downloaded rather than opened.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// countWriter counts the bytes written to it.
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
+
+// hashURL streams url through srv without saving it. The size is taken from
+// Content-Length when the server sends one, otherwise from the bytes read.
+func hashURL(ctx context.Context, srv xsum.Server, url string) (size int64, sums map[string][]byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	var read countWriter
+	if sums, err = xsum.HashReader(srv, io.TeeReader(resp.Body, &read)); err != nil {
+		return int64(read), nil, err
+	}
+	if resp.ContentLength >= 0 {
+		return resp.ContentLength, sums, nil
+	}
+	return int64(read), sums, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walkFunc receives each regular file found by walkTree, or the error for a
+// directory or link that could not be read. Returning an error stops the walk.
+type walkFunc func(path string, info fs.FileInfo, err error) error
+
+// walkTree calls fn for every regular file under root for -r. Symlinks are
+// skipped unless follow is set (-L); then links to files are included and
+// links to directories are walked, each real directory at most once so a
+// link back up the tree cannot loop. Errors reading part of the tree are
+// passed to fn and the rest of the tree is still walked.
+func walkTree(root string, follow bool, fn walkFunc) error {
+	visited := make(map[string]bool)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return fn(path, nil, err)
+			}
+			if d.IsDir() {
+				if follow {
+					real, err := filepath.EvalSymlinks(path)
+					if err != nil {
+						return fn(path, nil, err)
+					}
+					if visited[real] {
+						return filepath.SkipDir
+					}
+					visited[real] = true
+				}
+				return nil
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				if !follow {
+					return nil
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					return fn(path, nil, err)
+				}
+				if info.IsDir() {
+					// WalkDir does not follow a symlink root without a
+					// trailing separator.
+					return walk(path + string(filepath.Separator))
+				}
+				if info.Mode().IsRegular() {
+					return fn(path, info, nil)
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				// Removed since the directory was read.
+				return fn(path, nil, err)
+			}
+			return fn(path, info, nil)
+		})
+	}
+	return walk(root)
+}
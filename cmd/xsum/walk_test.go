@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWalkTree(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	for _, name := range []string{"root/a", "root/sub/b", "other/c"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"root/link-c":     "../other/c",
+		"root/link-other": "../other",
+		"root/sub/loop":   "..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	walk := func(follow bool) (files []string) {
+		err := walkTree(root, follow, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				t.Errorf("%s: %v", path, err)
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(files)
+		return
+	}
+
+	if got, want := walk(false), []string{"a", "sub/b"}; !slices.Equal(got, want) {
+		t.Errorf("without -L got %v, want %v", got, want)
+	}
+	if got, want := walk(true), []string{"a", "link-c", "link-other/c", "sub/b"}; !slices.Equal(got, want) {
+		t.Errorf("with -L got %v, want %v", got, want)
+	}
+}
@@ -4,11 +4,30 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"time"
 )
 
 // DefaultEditor is the default text editor used if no other is specified.
 var DefaultEditor = "vi"
 
+// NoEdit skips running the editor entirely, so content is returned unchanged.
+// It is meant for tests and CI where no editor is available. Setting EDITOR
+// to "true" has the same effect as long as a true command is on the PATH.
+var NoEdit = false
+
+// EditResult describes a completed edit session.
+type EditResult struct {
+	// Editor is the editor command that was run.
+	Editor string
+	// Duration is how long the editor was open.
+	Duration time.Duration
+	// Changed indicates whether the edited content differs from the input.
+	Changed bool
+	// Content is the file content after editing.
+	Content string
+}
+
 // GetEditor returns the editor to be used, either from the EDITOR environment variable or the default editor.
 // It also checks if the editor is available in the system's PATH.
 func GetEditor() (editor string, err error) {
@@ -21,9 +40,115 @@ func GetEditor() (editor string, err error) {
 	return editor, nil
 }
 
+// cleanEnv lists the environment variables kept by EditTempFileClean.
+var cleanEnv = []string{"PATH", "TERM", "HOME", "LANG", "LC_ALL"}
+
+// runEditor opens filename in the editor attached to the terminal and waits for it to exit.
+// When clean is set the editor gets only the variables in cleanEnv, and vim or
+// nvim is started with -u NONE so no user configuration or plugins are loaded.
+func runEditor(filename string, clean bool) (editor string, err error) {
+	if NoEdit {
+		return "", nil
+	}
+	if editor, err = GetEditor(); err != nil {
+		return
+	}
+	args := []string{filename}
+	var env []string
+	if clean {
+		switch filepath.Base(editor) {
+		case "vim", "nvim":
+			args = append([]string{"-u", "NONE"}, args...)
+		}
+		env = []string{}
+		for _, key := range cleanEnv {
+			if value, ok := os.LookupEnv(key); ok {
+				env = append(env, key+"="+value)
+			}
+		}
+	}
+	cmd := exec.Command(editor, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	err = cmd.Run()
+	return
+}
+
+// EditFile opens an existing file in the editor and returns its contents after editing.
+// Unlike EditTempFile the file is edited in place and kept.
+func EditFile(path string) (edited string, err error) {
+	if _, err = runEditor(path, false); err != nil {
+		return
+	}
+	var b []byte
+	if b, err = os.ReadFile(path); err != nil {
+		return
+	}
+	return string(b), nil
+}
+
 // EditTempFile creates a temporary file with the given contents and opens it in the editor for editing.
 // The edited contents are returned as a string.
 func EditTempFile(contents string, pattern string) (edited string, err error) {
+	var result EditResult
+	if result, err = EditTempFileResult(contents, pattern); err != nil {
+		return
+	}
+	return result.Content, nil
+}
+
+// EditTempFileResult is like EditTempFile but also reports which editor ran,
+// how long the session lasted and whether the content changed.
+func EditTempFileResult(contents string, pattern string) (result EditResult, err error) {
+	return editTempFile(contents, pattern, false)
+}
+
+// EditTempFileClean is like EditTempFileResult but runs the editor with a
+// minimal environment (PATH, TERM, HOME and the locale) and, for vim and nvim,
+// without user configuration. It is meant for tools that need predictable
+// editing; interactive callers should keep using EditTempFile.
+func EditTempFileClean(contents string, pattern string) (result EditResult, err error) {
+	return editTempFile(contents, pattern, true)
+}
+
+// EditTempFileBackup is like EditTempFileResult but first writes contents to
+// a recovery file in backupDir, created if needed and synced to disk, so the
+// starting point survives a crash of the editor or the calling program. The
+// recovery file is removed when the edit succeeds; on error it is kept and
+// its path is included in the error.
+func EditTempFileBackup(contents string, pattern string, backupDir string) (result EditResult, err error) {
+	if pattern == "" {
+		pattern = "*.txt"
+	}
+	if err = os.MkdirAll(backupDir, 0o700); err != nil {
+		return
+	}
+	var f *os.File
+	if f, err = os.CreateTemp(backupDir, pattern); err != nil {
+		return
+	}
+	backup := f.Name()
+	if _, err = f.WriteString(contents); err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(backup)
+		return
+	}
+
+	if result, err = editTempFile(contents, pattern, false); err != nil {
+		return result, fmt.Errorf("%w (original content kept in %s)", err, backup)
+	}
+	os.Remove(backup)
+	return
+}
+
+func editTempFile(contents string, pattern string, clean bool) (result EditResult, err error) {
 	if pattern == "" {
 		pattern = "*.txt"
 	}
@@ -38,23 +163,73 @@ func EditTempFile(contents string, pattern string) (edited string, err error) {
 	}
 	defer os.Remove(tmpFilename)
 
+	start := time.Now()
 	var editor string
-	if editor, err = GetEditor(); err != nil {
-		return
-	}
-	cmd := exec.Command(editor, tmpFilename)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	if err = cmd.Run(); err != nil {
+	if editor, err = runEditor(tmpFilename, clean); err != nil {
 		return
 	}
+	duration := time.Since(start)
 
 	var b []byte
 	if b, err = os.ReadFile(tmpFilename); err != nil {
 		return
 	}
 
-	edited = string(b)
+	result = EditResult{
+		Editor:   editor,
+		Duration: duration,
+		Changed:  string(b) != contents,
+		Content:  string(b),
+	}
 	return
 }
+
+// DefaultPagers are tried in order when the PAGER environment variable is not set.
+var DefaultPagers = []string{"less", "more"}
+
+// GetPager returns the pager to be used, either from the PAGER environment variable or the
+// first of DefaultPagers found in the system's PATH.
+func GetPager() (pager string, err error) {
+	if pager = os.Getenv("PAGER"); pager != "" {
+		if _, err = exec.LookPath(pager); err != nil {
+			return "", fmt.Errorf("pager %s not found", pager)
+		}
+		return pager, nil
+	}
+	for _, pager = range DefaultPagers {
+		if _, err = exec.LookPath(pager); err == nil {
+			return pager, nil
+		}
+	}
+	return "", fmt.Errorf("no pager found, tried %v", DefaultPagers)
+}
+
+// Page shows contents read-only in the pager attached to the terminal, through
+// a temporary file that is removed afterwards. Nothing is read back.
+// Like the editor, the pager is not run when NoEdit is set.
+func Page(contents string) (err error) {
+	if NoEdit {
+		return nil
+	}
+	var pager string
+	if pager, err = GetPager(); err != nil {
+		return
+	}
+	var f *os.File
+	if f, err = os.CreateTemp("", "*.txt"); err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(contents); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	cmd := exec.Command(pager, f.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	return cmd.Run()
+}
@@ -0,0 +1,169 @@
+package txtedit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeEditor installs a shell script named name as EDITOR for the test and
+// returns its path. The script gets the file to edit as "$1".
+func writeEditor(t *testing.T, name, script string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", path)
+	return path
+}
+
+func TestEditTempFileResult(t *testing.T) {
+	editor := writeEditor(t, "editor", `printf after > "$1"`)
+	result, err := EditTempFileResult("before", "result-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Editor != editor || result.Content != "after" || !result.Changed || result.Duration <= 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	writeEditor(t, "editor", ":")
+	if result, err = EditTempFileResult("same", ""); err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != "same" || result.Changed {
+		t.Fatalf("unexpected result for an unchanged file %+v", result)
+	}
+	if content, err := EditTempFile("same", ""); err != nil || content != "same" {
+		t.Fatalf("EditTempFile: got %q, %v", content, err)
+	}
+}
+
+func TestEditFile(t *testing.T) {
+	writeEditor(t, "editor", `printf edited >> "$1"`)
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("kept "), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	content, err := EditFile(path)
+	if err != nil || content != "kept edited" {
+		t.Fatalf("got %q, %v", content, err)
+	}
+	// The file is edited in place, not through a copy.
+	if b, err := os.ReadFile(path); err != nil || string(b) != "kept edited" {
+		t.Fatalf("file holds %q, %v", b, err)
+	}
+}
+
+func TestNoEdit(t *testing.T) {
+	defer func(noEdit bool) { NoEdit = noEdit }(NoEdit)
+	NoEdit = true
+	// An editor that would fail the edit is never run.
+	writeEditor(t, "editor", "exit 1")
+	result, err := EditTempFileResult("unchanged", "")
+	if err != nil || result.Content != "unchanged" || result.Changed {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+
+	NoEdit = false
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("no true command")
+	}
+	t.Setenv("EDITOR", "true")
+	if content, err := EditTempFile("unchanged", ""); err != nil || content != "unchanged" {
+		t.Fatalf("EDITOR=true: got %q, %v", content, err)
+	}
+}
+
+func TestEditTempFileClean(t *testing.T) {
+	t.Setenv("TXTEDIT_TEST_SECRET", "leak")
+	t.Setenv("TERM", "dumb")
+	writeEditor(t, "editor", `env > "$1"`)
+	result, err := EditTempFileClean("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := "\n" + result.Content
+	if strings.Contains(env, "\nTXTEDIT_TEST_SECRET=") || !strings.Contains(env, "\nTERM=dumb\n") {
+		t.Fatalf("unexpected editor environment:\n%s", result.Content)
+	}
+	// The default keeps the whole environment.
+	if result, err = EditTempFileResult("", ""); err != nil || !strings.Contains(result.Content, "TXTEDIT_TEST_SECRET=leak") {
+		t.Fatalf("expected the full environment, got %v:\n%s", err, result.Content)
+	}
+
+	// vim and nvim are started without user configuration.
+	writeEditor(t, "vim", `for last; do :; done; printf '%s ' "$@" > "$last"`)
+	if result, err = EditTempFileClean("", ""); err != nil || !strings.HasPrefix(result.Content, "-u NONE ") {
+		t.Fatalf("vim arguments: %q, %v", result.Content, err)
+	}
+}
+
+func TestPage(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	dir := t.TempDir()
+	shown := filepath.Join(dir, "shown")
+	pager := filepath.Join(dir, "pager")
+	if err := os.WriteFile(pager, []byte("#!/bin/sh\ncat \"$1\" > "+shown+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PAGER", pager)
+	if err := Page("preview"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(shown); err != nil || string(b) != "preview" {
+		t.Fatalf("pager showed %q, %v", b, err)
+	}
+
+	// Without PAGER the first of DefaultPagers on the PATH is used.
+	defer func(pagers []string) { DefaultPagers = pagers }(DefaultPagers)
+	DefaultPagers = []string{filepath.Join(dir, "missing"), pager}
+	t.Setenv("PAGER", "")
+	if got, err := GetPager(); err != nil || got != pager {
+		t.Fatalf("GetPager: got %q, %v", got, err)
+	}
+
+	defer func(noEdit bool) { NoEdit = noEdit }(NoEdit)
+	NoEdit = true
+	os.Remove(shown)
+	if err := Page("skipped"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(shown); !os.IsNotExist(err) {
+		t.Fatal("expected the pager not to run with NoEdit")
+	}
+}
+
+func TestEditTempFileBackup(t *testing.T) {
+	backupDir := filepath.Join(t.TempDir(), "recovery")
+	writeEditor(t, "editor", `printf after > "$1"`)
+	result, err := EditTempFileBackup("before", "backup-*.txt", backupDir)
+	if err != nil || result.Content != "after" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(backupDir, "*")); len(left) != 0 {
+		t.Fatalf("expected the recovery file to be removed, got %v", left)
+	}
+
+	// A failed edit keeps the original content for recovery.
+	writeEditor(t, "editor", `printf lost > "$1"; exit 1`)
+	if _, err = EditTempFileBackup("before", "backup-*.txt", backupDir); err == nil {
+		t.Fatal("expected the failed editor to be reported")
+	}
+	left, _ := filepath.Glob(filepath.Join(backupDir, "backup-*.txt"))
+	if len(left) != 1 || !strings.Contains(err.Error(), left[0]) {
+		t.Fatalf("expected one recovery file named in %q, got %v", err, left)
+	}
+	if b, err := os.ReadFile(left[0]); err != nil || string(b) != "before" {
+		t.Fatalf("recovery file holds %q, %v", b, err)
+	}
+}
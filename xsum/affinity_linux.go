@@ -0,0 +1,28 @@
+package xsum
+
+import "golang.org/x/sys/unix"
+
+// pinThread restricts the calling OS thread to the n-th CPU (modulo the count)
+// of the CPUs the process is currently allowed to run on.
+func pinThread(n int) error {
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		return err
+	}
+	count := allowed.Count()
+	if count == 0 {
+		return nil
+	}
+	n %= count
+	for cpu := 0; ; cpu++ {
+		if !allowed.IsSet(cpu) {
+			continue
+		}
+		if n == 0 {
+			var set unix.CPUSet
+			set.Set(cpu)
+			return unix.SchedSetaffinity(0, &set)
+		}
+		n--
+	}
+}
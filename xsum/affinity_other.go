@@ -0,0 +1,6 @@
+//go:build !linux
+
+package xsum
+
+// pinThread is a no-op on platforms without sched_setaffinity.
+func pinThread(int) error { return nil }
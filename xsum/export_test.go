@@ -0,0 +1,18 @@
+package xsum
+
+import "maps"
+
+// HasherOrder returns the algorithm names of h's digests in iteration order.
+func HasherOrder(h Hasher) []string {
+	m, ok := h.(*multiHasher)
+	if !ok {
+		return nil
+	}
+	var order []string
+	for _, hasher := range m.hashers {
+		for name := range maps.Keys(hasher.MultiSum()) {
+			order = append(order, name)
+		}
+	}
+	return order
+}
@@ -0,0 +1,64 @@
+package xsum
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// MemoryCache is a Cache held in memory for the life of the process, for
+// tests, short-lived programs and filesystems without extended attributes.
+// An entry is used only while the file keeps the size and modification time
+// it had when the entry was stored. It is safe for concurrent use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	size    int64
+	modTime time.Time
+	sums    map[string][]byte
+}
+
+var _ StatCache = (*MemoryCache)(nil)
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Cache. It returns nil if filename has no entry or has
+// changed size or modification time since it was stored.
+func (c *MemoryCache) Get(filename string) (map[string][]byte, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[filename]
+	if !ok || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		delete(c.entries, filename)
+		return nil, nil
+	}
+	return entry.sums, nil
+}
+
+// Set implements Cache, recording the file's current size and modification time.
+func (c *MemoryCache) Set(filename string, sums map[string][]byte) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return c.SetStat(filename, info, sums)
+}
+
+// SetStat implements StatCache, recording the size and modification time in info.
+func (c *MemoryCache) SetStat(filename string, info fs.FileInfo, sums map[string][]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[filename] = memoryEntry{info.Size(), info.ModTime(), sums}
+	return nil
+}
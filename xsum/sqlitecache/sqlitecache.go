@@ -0,0 +1,196 @@
+// Package sqlitecache implements an xsum.Cache in a SQLite database, keeping
+// every scan so checksums can be queried over time, e.g. to find files whose
+// content changed without their size or modification time changing.
+//
+// It lives outside package xsum so that only programs using it link the
+// SQLite driver.
+package sqlitecache
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ophymx/utils/xsum"
+	_ "modernc.org/sqlite"
+)
+
+// schemaVersion is stored in PRAGMA user_version; migrations bring older
+// databases up to it.
+const schemaVersion = 1
+
+// migrations[i] upgrades a database from user_version i to i+1.
+var migrations = []string{
+	`CREATE TABLE sums (
+		filename   TEXT    NOT NULL,
+		algorithm  TEXT    NOT NULL,
+		size       INTEGER NOT NULL,
+		mtime      INTEGER NOT NULL, -- Unix nanoseconds
+		digest     BLOB    NOT NULL,
+		scanned_at INTEGER NOT NULL  -- Unix nanoseconds
+	);
+	CREATE INDEX sums_filename_scanned_at ON sums (filename, scanned_at);
+	CREATE INDEX sums_scanned_at ON sums (scanned_at);`,
+}
+
+// Cache stores sums in a SQLite database. Every Set adds a row per algorithm,
+// so earlier scans are kept as history. Files are keyed by absolute path so
+// scans from different working directories share their history.
+type Cache struct {
+	db *sql.DB
+}
+
+var _ xsum.StatCache = (*Cache)(nil)
+
+// New opens or creates the database at dbPath and migrates its schema.
+func New(dbPath string) (*Cache, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	// Workers call Get and Set concurrently; one connection serializes them
+	// without SQLITE_BUSY errors.
+	db.SetMaxOpenConns(1)
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", dbPath, err)
+	}
+	return &Cache{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("schema version %d is newer than supported version %d", version, schemaVersion)
+	}
+	for ; version < schemaVersion; version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the database.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Get returns the sums from the latest scan of filename, or nil if there is
+// none or the file's size or modification time differ from that scan.
+func (c *Cache) Get(filename string) (map[string][]byte, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.Query(`
+		SELECT algorithm, size, mtime, digest FROM sums
+		WHERE filename = ?1 AND scanned_at = (SELECT MAX(scanned_at) FROM sums WHERE filename = ?1)`,
+		filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sums := make(map[string][]byte)
+	for rows.Next() {
+		var algorithm string
+		var size, mtime int64
+		var digest []byte
+		if err = rows.Scan(&algorithm, &size, &mtime, &digest); err != nil {
+			return nil, err
+		}
+		if size != info.Size() || mtime != info.ModTime().UnixNano() {
+			return nil, nil
+		}
+		sums[algorithm] = digest
+	}
+	if err = rows.Err(); err != nil || len(sums) == 0 {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// Set records a scan of filename with its current size and modification time.
+func (c *Cache) Set(filename string, sums map[string][]byte) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return c.SetStat(filename, info, sums)
+}
+
+// SetStat implements xsum.StatCache, recording a scan of filename with the
+// size and modification time in info.
+func (c *Cache) SetStat(filename string, info fs.FileInfo, sums map[string][]byte) error {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	scannedAt := time.Now().UnixNano()
+	for algorithm, digest := range sums {
+		if _, err = tx.Exec(
+			"INSERT INTO sums (filename, algorithm, size, mtime, digest, scanned_at) VALUES (?, ?, ?, ?, ?, ?)",
+			filename, algorithm, info.Size(), info.ModTime().UnixNano(), digest, scannedAt,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ChangedSince returns the files whose latest digest for algorithm differs
+// from the one recorded by the latest scan before since.
+func (c *Cache) ChangedSince(algorithm string, since time.Time) ([]string, error) {
+	rows, err := c.db.Query(`
+		WITH latest AS (
+			SELECT filename, digest, MAX(scanned_at) FROM sums
+			WHERE algorithm = ?1 GROUP BY filename
+		), before AS (
+			SELECT filename, digest, MAX(scanned_at) FROM sums
+			WHERE algorithm = ?1 AND scanned_at < ?2 GROUP BY filename
+		)
+		SELECT latest.filename FROM latest JOIN before USING (filename)
+		WHERE latest.digest != before.digest
+		ORDER BY latest.filename`,
+		algorithm, since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var filenames []string
+	for rows.Next() {
+		var filename string
+		if err = rows.Scan(&filename); err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, rows.Err()
+}
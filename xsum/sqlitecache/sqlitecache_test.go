@@ -0,0 +1,111 @@
+package sqlitecache_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ophymx/utils/xsum/sqlitecache"
+)
+
+func openCache(t *testing.T) (*sqlitecache.Cache, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "sums.db")
+	cache, err := sqlitecache.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache, dbPath
+}
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	cache, _ := openCache(t)
+	path := filepath.Join(t.TempDir(), "a")
+	writeFile(t, path, "hello", time.Unix(1000, 0))
+
+	if sums, err := cache.Get(path); err != nil || sums != nil {
+		t.Fatalf("expected a miss before Set, got %v, %v", sums, err)
+	}
+	want := map[string][]byte{"md5": {1, 2}, "sha256": {3, 4}}
+	if err := cache.Set(path, want); err != nil {
+		t.Fatal(err)
+	}
+	sums, err := cache.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 2 || string(sums["md5"]) != "\x01\x02" || string(sums["sha256"]) != "\x03\x04" {
+		t.Fatalf("got %v, want %v", sums, want)
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	cache, _ := openCache(t)
+	path := filepath.Join(t.TempDir(), "a")
+	writeFile(t, path, "hello", time.Unix(1000, 0))
+	if err := cache.Set(path, map[string][]byte{"sha256": {1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, path, "hello", time.Unix(2000, 0))
+	if sums, err := cache.Get(path); err != nil || sums != nil {
+		t.Fatalf("expected a miss after the mtime changed, got %v, %v", sums, err)
+	}
+
+	writeFile(t, path, "hello", time.Unix(1000, 0))
+	if sums, err := cache.Get(path); err != nil || sums == nil {
+		t.Fatalf("expected a hit with the original mtime, got %v, %v", sums, err)
+	}
+
+	writeFile(t, path, "hello, world", time.Unix(1000, 0))
+	if sums, err := cache.Get(path); err != nil || sums != nil {
+		t.Fatalf("expected a miss after the size changed, got %v, %v", sums, err)
+	}
+}
+
+func TestReopenAndChangedSince(t *testing.T) {
+	cache, dbPath := openCache(t)
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeFile(t, a, "a", time.Unix(1000, 0))
+	writeFile(t, b, "b", time.Unix(1000, 0))
+	cache.Set(a, map[string][]byte{"sha256": {1}})
+	cache.Set(b, map[string][]byte{"sha256": {2}})
+	cache.Close()
+
+	reopened, err := sqlitecache.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if sums, err := reopened.Get(a); err != nil || sums == nil {
+		t.Fatalf("expected sums to survive reopening, got %v, %v", sums, err)
+	}
+
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+	// Bit rot: the content changed but size and mtime did not.
+	reopened.Set(a, map[string][]byte{"sha256": {9}})
+	reopened.Set(b, map[string][]byte{"sha256": {2}})
+
+	changed, err := reopened.ChangedSince("sha256", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{a}) {
+		t.Fatalf("ChangedSince = %v, want [%s]", changed, a)
+	}
+}
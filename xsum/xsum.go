@@ -3,19 +3,29 @@ package xsum
 import (
 	"context"
 	"crypto/sha1"
+	"crypto/sha3"
 	"crypto/sha512"
 	"errors"
 	"fmt"
 	"hash"
+	"hash/crc32"
+	"hash/crc64"
 	"io"
+	"io/fs"
 	"maps"
 	"os"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/klauspost/cpuid/v2"
 	md5simd "github.com/minio/md5-simd"
 	sha256simd "github.com/minio/sha256-simd"
+	"github.com/zeebo/xxh3"
+	"lukechampine.com/blake3"
 )
 
 // Hasher is a multi-algorithm hash that writes data once and returns per-algorithm
@@ -32,16 +42,31 @@ type Hasher interface {
 // Server creates Hasher instances and owns any underlying SIMD servers.
 type Server interface {
 	NewHash() Hasher
+	// Algorithms returns the sorted names of the algorithms the server computes.
+	Algorithms() []string
 	Close() error
 }
 
 // Cache is an optional read/write store for previously computed sums.
-// Any miss (empty map or error) causes a full recompute of all algorithms.
+// Any miss (empty map, error, or sums lacking one of the server's algorithms)
+// causes a full recompute of all algorithms.
 type Cache interface {
 	Get(filename string) (map[string][]byte, error)
 	Set(filename string, sums map[string][]byte) error
 }
 
+// StatCache is a Cache that can store sums with the file info taken before
+// the file was read, so a file modified while it was being hashed is stale
+// on the next Get instead of cached with the changed content's time.
+// Parallel calls SetStat in place of Set for caches that implement it.
+type StatCache interface {
+	Cache
+	SetStat(filename string, info fs.FileInfo, sums map[string][]byte) error
+}
+
+// ErrFileChanged is reported when a file is truncated while it is being hashed.
+var ErrFileChanged = errors.New("file changed while hashing")
+
 // OnResult is called when a file's hash computation is done.
 type OnResult func(filename string, sums map[string][]byte, err error)
 
@@ -55,14 +80,13 @@ func (h *md5Hasher) MultiSum() map[string][]byte {
 
 type md5Server struct{ srv md5simd.Server }
 
-func newMD5Server() *md5Server       { return &md5Server{srv: md5simd.NewServer()} }
-func (s *md5Server) NewHash() Hasher { return &md5Hasher{s.srv.NewHash()} }
-func (s *md5Server) Close() error    { s.srv.Close(); return nil }
+func newMD5Server() *md5Server            { return &md5Server{srv: md5simd.NewServer()} }
+func (s *md5Server) NewHash() Hasher      { return &md5Hasher{s.srv.NewHash()} }
+func (s *md5Server) Algorithms() []string { return []string{"md5"} }
+func (s *md5Server) Close() error         { s.srv.Close(); return nil }
 
 // ── sha256 leaf ───────────────────────────────────────────────────────────────
 
-var hasAvx512 = cpuid.CPU.Supports(cpuid.AVX512F, cpuid.AVX512DQ, cpuid.AVX512BW, cpuid.AVX512VL)
-
 type sha256Hasher struct{ hash.Hash }
 
 func (h *sha256Hasher) Close() {}
@@ -70,25 +94,20 @@ func (h *sha256Hasher) MultiSum() map[string][]byte {
 	return map[string][]byte{"sha256": h.Sum(nil)}
 }
 
-type sha256Server struct{ avx512 *sha256simd.Avx512Server }
+// sha256Server does not use sha256-simd's AVX-512 multi-buffer server: its
+// digests queue the caller's slices and their own partial block to a server
+// goroutine without copying them, so a reused read buffer, or any write that
+// is not a whole number of blocks, corrupts the sum.
+type sha256Server struct{}
 
-func newSHA256Server() *sha256Server {
-	if hasAvx512 {
-		return &sha256Server{avx512: sha256simd.NewAvx512Server()}
-	}
-	return &sha256Server{}
-}
+func newSHA256Server() *sha256Server { return &sha256Server{} }
 
-func (s *sha256Server) NewHash() Hasher {
-	if s.avx512 != nil {
-		return &sha256Hasher{sha256simd.NewAvx512(s.avx512)}
-	}
-	return &sha256Hasher{sha256simd.New()}
-}
+func (s *sha256Server) NewHash() Hasher { return &sha256Hasher{sha256simd.New()} }
 
-func (s *sha256Server) Close() error { return nil }
+func (s *sha256Server) Algorithms() []string { return []string{"sha256"} }
+func (s *sha256Server) Close() error         { return nil }
 
-// ── stdlib leaf (sha1, sha512) ────────────────────────────────────────────────
+// ── hash.Hash leaf (sha1, sha512, sha3, blake3, xxh64, xxh3, crc) ──────────────────
 
 type stdHasher struct {
 	hash.Hash
@@ -105,25 +124,75 @@ type stdServer struct {
 	newHash func() hash.Hash
 }
 
-func (s *stdServer) NewHash() Hasher { return &stdHasher{s.newHash(), s.name} }
-func (s *stdServer) Close() error    { return nil }
+func (s *stdServer) NewHash() Hasher      { return &stdHasher{s.newHash(), s.name} }
+func (s *stdServer) Algorithms() []string { return []string{s.name} }
+func (s *stdServer) Close() error         { return nil }
+
+// newBLAKE3 returns an unkeyed BLAKE3 hash with the standard 32-byte digest.
+func newBLAKE3() hash.Hash { return blake3.New(32, nil) }
+
+// newSHA3_256 and newSHA3_512 return the FIPS 202 SHA-3 hashes.
+func newSHA3_256() hash.Hash { return sha3.New256() }
+func newSHA3_512() hash.Hash { return sha3.New512() }
+
+// newXXH64 and newXXH3 return the fast non-cryptographic XXH64 and 64-bit
+// XXH3 hashes, both with a zero seed and an 8-byte big-endian digest as
+// printed by xxhsum.
+func newXXH64() hash.Hash { return xxhash.New() }
+func newXXH3() hash.Hash  { return xxh3.New() }
+
+// crc64Table is the ISO polynomial table shared by every crc64 hash.
+var crc64Table = crc64.MakeTable(crc64.ISO)
+
+// newCRC32 and newCRC64 return the IEEE CRC-32 and ISO CRC-64 checksums with
+// 4- and 8-byte big-endian digests, for tools that still emit them.
+func newCRC32() hash.Hash { return crc32.NewIEEE() }
+func newCRC64() hash.Hash { return crc64.New(crc64Table) }
 
 // ── multi hasher + server ─────────────────────────────────────────────────────
 
+// multiHasher fans writes out to its hashers, which are kept in algorithm
+// name order so Write, Reset and MultiSum visit them deterministically.
 type multiHasher struct {
-	io.Writer
 	hashers   []Hasher
 	blockSize int
 }
 
 func newMultiHasher(hashers []Hasher) *multiHasher {
-	writers := make([]io.Writer, len(hashers))
 	blockSize := 1
-	for i, h := range hashers {
-		writers[i] = h
+	for _, h := range hashers {
 		blockSize = lcm(blockSize, h.BlockSize())
 	}
-	return &multiHasher{Writer: io.MultiWriter(writers...), hashers: hashers, blockSize: blockSize}
+	return &multiHasher{hashers: hashers, blockSize: blockSize}
+}
+
+// concurrentWriteMin is the smallest write that is fanned out to the hashers
+// concurrently; smaller writes are cheaper to feed serially.
+const concurrentWriteMin = 64 * 1024
+
+// Write feeds p to every hasher. Large writes run each digest on its own
+// goroutine and wait for all of them, so the slowest digest throttles the
+// reader and p is never retained past the call.
+func (h *multiHasher) Write(p []byte) (int, error) {
+	if len(p) < concurrentWriteMin {
+		for _, hasher := range h.hashers {
+			if _, err := hasher.Write(p); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	errs := make([]error, len(h.hashers))
+	var wg sync.WaitGroup
+	for i, hasher := range h.hashers[1:] {
+		wg.Go(func() { _, errs[i+1] = hasher.Write(p) })
+	}
+	_, errs[0] = h.hashers[0].Write(p)
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
 func (h *multiHasher) Close() {
@@ -158,6 +227,15 @@ func (s *multiServer) NewHash() Hasher {
 	return newMultiHasher(hashers)
 }
 
+func (s *multiServer) Algorithms() []string {
+	var algorithms []string
+	for _, srv := range s.servers {
+		algorithms = append(algorithms, srv.Algorithms()...)
+	}
+	slices.Sort(algorithms)
+	return slices.Compact(algorithms)
+}
+
 func (s *multiServer) Close() error {
 	var errs []error
 	for _, srv := range s.servers {
@@ -170,14 +248,23 @@ func (s *multiServer) Close() error {
 
 // ── construction ──────────────────────────────────────────────────────────────
 
-// NewServer creates a Server for the named algorithms ("md5", "sha256", "sha1", "sha512").
-// A single algorithm returns a leaf Server directly; multiple algorithms return a multiServer.
+// NewServer creates a Server for the named algorithms ("md5", "sha256", "sha1",
+// "sha512", "sha3-256", "sha3-512", "blake3", "xxh64", "xxh3", "crc32", "crc64"). xxh64, xxh3
+// and the crcs are not cryptographic and only suited to detecting accidental
+// changes or deduplication.
+// Repeated names are ignored. A single algorithm returns a leaf Server directly;
+// multiple algorithms return a multiServer.
 func NewServer(algorithms ...string) (Server, error) {
 	if len(algorithms) == 0 {
 		return nil, errors.New("at least one algorithm is required")
 	}
 	servers := make([]Server, 0, len(algorithms))
+	seen := make(map[string]bool, len(algorithms))
 	for _, algorithm := range algorithms {
+		if seen[algorithm] {
+			continue
+		}
+		seen[algorithm] = true
 		switch algorithm {
 		case "md5":
 			servers = append(servers, newMD5Server())
@@ -187,6 +274,20 @@ func NewServer(algorithms ...string) (Server, error) {
 			servers = append(servers, &stdServer{"sha1", sha1.New})
 		case "sha512":
 			servers = append(servers, &stdServer{"sha512", sha512.New})
+		case "sha3-256":
+			servers = append(servers, &stdServer{"sha3-256", newSHA3_256})
+		case "sha3-512":
+			servers = append(servers, &stdServer{"sha3-512", newSHA3_512})
+		case "blake3":
+			servers = append(servers, &stdServer{"blake3", newBLAKE3})
+		case "xxh64":
+			servers = append(servers, &stdServer{"xxh64", newXXH64})
+		case "xxh3":
+			servers = append(servers, &stdServer{"xxh3", newXXH3})
+		case "crc32":
+			servers = append(servers, &stdServer{"crc32", newCRC32})
+		case "crc64":
+			servers = append(servers, &stdServer{"crc64", newCRC64})
 		default:
 			for _, s := range servers {
 				s.Close()
@@ -197,6 +298,82 @@ func NewServer(algorithms ...string) (Server, error) {
 	if len(servers) == 1 {
 		return servers[0], nil
 	}
+	// Keep leaves ordered by name so every hasher iterates its digests in
+	// the same order regardless of how the algorithms were listed.
+	slices.SortFunc(servers, func(a, b Server) int {
+		return strings.Compare(a.Algorithms()[0], b.Algorithms()[0])
+	})
+	return &multiServer{servers: servers}, nil
+}
+
+// SupportedAlgorithms returns the algorithm names NewServer accepts.
+func SupportedAlgorithms() []string {
+	return []string{"blake3", "crc32", "crc64", "md5", "sha1", "sha256", "sha3-256", "sha3-512", "sha512", "xxh3", "xxh64"}
+}
+
+// Implementation describes the code path NewServer selects for algorithm on
+// the current CPU, e.g. "sha256-simd SHA-NI" or "crypto/sha256".
+func Implementation(algorithm string) string {
+	switch algorithm {
+	case "md5":
+		if runtime.GOARCH == "amd64" && cpuid.CPU.Supports(cpuid.AVX2) {
+			return "md5-simd AVX2"
+		}
+		return "crypto/md5"
+	case "sha256":
+		switch {
+		case runtime.GOARCH == "amd64" && cpuid.CPU.Supports(cpuid.SHA, cpuid.SSSE3, cpuid.SSE4):
+			return "sha256-simd SHA-NI"
+		case runtime.GOARCH == "arm64" && cpuid.CPU.Supports(cpuid.SHA2):
+			return "sha256-simd ARMv8 SHA2"
+		}
+		return "crypto/sha256"
+	case "sha1", "sha512":
+		return "crypto/" + algorithm
+	case "sha3-256", "sha3-512":
+		return "crypto/sha3"
+	case "crc32", "crc64":
+		return "hash/" + algorithm
+	case "blake3":
+		switch {
+		case runtime.GOARCH == "amd64" && cpuid.CPU.Supports(cpuid.AVX512F):
+			return "blake3 AVX-512"
+		case runtime.GOARCH == "amd64" && cpuid.CPU.Supports(cpuid.AVX2):
+			return "blake3 AVX2"
+		}
+		return "blake3 generic"
+	case "xxh64":
+		return "cespare/xxhash"
+	case "xxh3":
+		switch {
+		case runtime.GOARCH == "amd64" && cpuid.CPU.Supports(cpuid.AVX512F):
+			return "xxh3 AVX-512"
+		case runtime.GOARCH == "amd64" && cpuid.CPU.Supports(cpuid.AVX2):
+			return "xxh3 AVX2"
+		}
+		if runtime.GOARCH == "amd64" {
+			return "xxh3 SSE2"
+		}
+		return "xxh3 generic"
+	}
+	return ""
+}
+
+// Combine returns a Server whose hashers feed every given server from a single
+// write, so independent digest groups (say one cryptographic, one fast) are
+// computed with one read of each file instead of one per group. The groups
+// then run on the same worker, trading extra CPU per file and one hasher per
+// group in memory for half (or less) of the I/O. MultiSum merges the sums of
+// every group; split them again with each server's Algorithms.
+// Closing the combined Server closes every member. At least one server is
+// required.
+func Combine(servers ...Server) (Server, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("at least one server is required")
+	}
+	if len(servers) == 1 {
+		return servers[0], nil
+	}
 	return &multiServer{servers: servers}, nil
 }
 
@@ -206,25 +383,134 @@ type result struct {
 	filename string
 	sums     map[string][]byte
 	err      error
+	stats    *HashStats
 }
 
-var copyBufPool = sync.Pool{
-	New: func() any { b := make([]byte, 2*1024*1024); return &b },
+// DefaultBufferSize is the read buffer size used when Options.BufferSize is zero.
+const DefaultBufferSize = 1024 * 1024
+
+var copyBufPool = newBufPool(DefaultBufferSize)
+
+// newBufPool returns a pool of size byte read buffers.
+func newBufPool(size int) *sync.Pool {
+	return &sync.Pool{
+		New: func() any { b := make([]byte, size); return &b },
+	}
 }
 
-func hashFile(filename string, h Hasher) (map[string][]byte, error) {
+// copyPooled copies r into h using a buffer from pool. r is wrapped so only
+// Read is visible: *os.File implements io.WriterTo, which io.CopyBuffer
+// would otherwise prefer, writing in its own 32 KiB chunks and ignoring buf.
+func copyPooled(pool *sync.Pool, h Hasher, r io.Reader) (int64, error) {
+	buf := pool.Get().(*[]byte)
+	defer pool.Put(buf)
+	return io.CopyBuffer(h, struct{ io.Reader }{r}, *buf)
+}
+
+// HashReader reads r to EOF into a new hasher from srv and returns the sums.
+func HashReader(srv Server, r io.Reader) (map[string][]byte, error) {
+	h := srv.NewHash()
 	defer h.Close()
-	f, err := os.Open(filename)
+	if _, err := copyPooled(copyBufPool, h, r); err != nil {
+		return nil, err
+	}
+	return h.MultiSum(), nil
+}
+
+// HashingReader is an io.Reader that hashes the data read through it.
+type HashingReader struct {
+	r    io.Reader
+	srv  Server
+	h    Hasher
+	sums map[string][]byte
+}
+
+// NewHashingReader returns a reader that reads from r and hashes everything
+// read with the named algorithms. Read r through it to EOF, then call Sums.
+// Close releases the hasher if the stream is abandoned before EOF.
+func NewHashingReader(r io.Reader, algorithms ...string) (*HashingReader, error) {
+	srv, err := NewServer(algorithms...)
 	if err != nil {
 		return nil, err
 	}
+	return &HashingReader{r: r, srv: srv, h: srv.NewHash()}, nil
+}
+
+func (hr *HashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	if n > 0 && hr.h != nil {
+		hr.h.Write(p[:n])
+	}
+	if err == io.EOF && hr.h != nil {
+		hr.sums = hr.h.MultiSum()
+		hr.Close()
+	}
+	return n, err
+}
+
+// Sums returns the digests of the data read, or nil if EOF has not been reached.
+func (hr *HashingReader) Sums() map[string][]byte {
+	return hr.sums
+}
+
+// Close releases the hasher. It does not close the underlying reader.
+func (hr *HashingReader) Close() error {
+	if hr.h == nil {
+		return nil
+	}
+	hr.h.Close()
+	hr.h = nil
+	return hr.srv.Close()
+}
+
+// fileHasher holds the per-run settings shared by every hashFile call.
+type fileHasher struct {
+	openFiles chan struct{}
+	snapshot  bool
+	bufPool   *sync.Pool
+}
+
+func newFileHasher(opts Options) *fileHasher {
+	fh := &fileHasher{snapshot: opts.Snapshot, bufPool: copyBufPool}
+	if opts.BufferSize > 0 && opts.BufferSize != DefaultBufferSize {
+		fh.bufPool = newBufPool(opts.BufferSize)
+	}
+	if opts.MaxOpenFiles > 0 {
+		fh.openFiles = make(chan struct{}, opts.MaxOpenFiles)
+	}
+	return fh
+}
+
+// hashFile hashes filename with h. It also returns the file's info from
+// before it was read, for StatCache.
+func (fh *fileHasher) hashFile(filename string, h Hasher) (map[string][]byte, *HashStats, fs.FileInfo, error) {
+	defer h.Close()
+	if fh.openFiles != nil {
+		fh.openFiles <- struct{}{}
+		defer func() { <-fh.openFiles }()
+	}
+	start := time.Now()
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	defer f.Close()
-	buf := copyBufPool.Get().(*[]byte)
-	defer copyBufPool.Put(buf)
-	if _, err = io.CopyBuffer(h, f, *buf); err != nil {
-		return nil, err
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return h.MultiSum(), nil
+	var r io.Reader = f
+	if fh.snapshot {
+		r = io.LimitReader(f, info.Size())
+	}
+	n, err := copyPooled(fh.bufPool, h, r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if fh.snapshot && n < info.Size() {
+		return nil, nil, nil, ErrFileChanged
+	}
+	return h.MultiSum(), &HashStats{Bytes: n, Elapsed: time.Since(start)}, info, nil
 }
 
 const maxWorkers = 16
@@ -240,17 +526,120 @@ func numWorkers() int {
 	return n
 }
 
+// Options tunes how ParallelOptions schedules work. The zero value gives the
+// same behaviour as Parallel.
+type Options struct {
+	// Affinity pins each worker goroutine to its own CPU so a worker keeps
+	// hashing on one core. Only supported on Linux; elsewhere it is a no-op.
+	Affinity bool
+	// Workers is the number of files hashed at once. Zero or less means one
+	// per CPU, up to 16.
+	Workers int
+	// MaxOpenFiles bounds how many files are open at once across all workers.
+	// Zero or less means no bound beyond the number of workers.
+	MaxOpenFiles int
+	// Snapshot hashes only the bytes present when each file is opened, so
+	// data appended while reading is ignored. A file that shrinks while it is
+	// read is reported with ErrFileChanged.
+	Snapshot bool
+	// BufferSize is the size of the buffer each file is read with. Zero or
+	// less means DefaultBufferSize.
+	BufferSize int
+	// OnHashed, if set, is called before onResult for each file that was
+	// actually read and hashed (not for cache hits or failures).
+	OnHashed func(filename string, stats HashStats)
+	// ReportCanceled reports every file left unprocessed when ctx is
+	// cancelled to onResult with ctx.Err(), so each filename gets exactly one
+	// result. Otherwise those files are silently skipped.
+	ReportCanceled bool
+}
+
+// HashStats describes the work done to hash one file.
+type HashStats struct {
+	// Bytes is the number of bytes read.
+	Bytes int64
+	// Elapsed is the time spent opening, reading and hashing the file.
+	Elapsed time.Duration
+}
+
+// cachedSums returns the sums cache holds for filename, limited to
+// algorithms, or nil unless it has every one of them.
+func cachedSums(cache Cache, filename string, algorithms []string) map[string][]byte {
+	cached, err := cache.Get(filename)
+	if err != nil {
+		return nil
+	}
+	sums := make(map[string][]byte, len(algorithms))
+	for _, algorithm := range algorithms {
+		sum, ok := cached[algorithm]
+		if !ok {
+			return nil
+		}
+		sums[algorithm] = sum
+	}
+	return sums
+}
+
+// hashCached returns the sums for filename from cache if it has all of
+// algorithms, and otherwise hashes the file and stores the sums in cache.
+// Cache errors are ignored: a failed Get is a miss and a failed Set only
+// means the file is hashed again next time.
+func (fh *fileHasher) hashCached(srv Server, cache Cache, algorithms []string, filename string) *result {
+	if cache != nil {
+		if sums := cachedSums(cache, filename, algorithms); sums != nil {
+			return &result{filename, sums, nil, nil}
+		}
+	}
+	sums, stats, info, err := fh.hashFile(filename, srv.NewHash())
+	if statCache, ok := cache.(StatCache); ok && err == nil {
+		_ = statCache.SetStat(filename, info, sums)
+	} else if cache != nil && err == nil {
+		_ = cache.Set(filename, sums)
+	}
+	return &result{filename, sums, err, stats}
+}
+
+// HashFile computes the sums of a single file on the calling goroutine,
+// consulting and updating cache like Parallel when it is non-nil. It suits
+// callers with their own concurrency; Parallel is faster for many files.
+func HashFile(srv Server, cache Cache, filename string) (map[string][]byte, error) {
+	r := newFileHasher(Options{}).hashCached(srv, cache, srv.Algorithms(), filename)
+	return r.sums, r.err
+}
+
 // Parallel computes hash sums for multiple files concurrently.
 // If cache is non-nil it is consulted before hashing and updated after.
 // Workers stop between files if ctx is cancelled; in-progress file reads run to completion.
 func Parallel(ctx context.Context, srv Server, cache Cache, filenames []string, onResult OnResult) {
-	nw := numWorkers()
+	ParallelOptions(ctx, srv, cache, filenames, Options{}, onResult)
+}
+
+// ParallelContext is Parallel for callers that need a result for every
+// file: once ctx is cancelled no new files are started, and each file not
+// yet processed is reported to onResult with ctx.Err(). It returns after
+// every worker has exited and the last onResult call has returned.
+func ParallelContext(ctx context.Context, srv Server, cache Cache, filenames []string, onResult OnResult) {
+	ParallelOptions(ctx, srv, cache, filenames, Options{ReportCanceled: true}, onResult)
+}
+
+// ParallelOptions is Parallel with explicit Options.
+func ParallelOptions(ctx context.Context, srv Server, cache Cache, filenames []string, opts Options, onResult OnResult) {
+	nw := opts.Workers
+	if nw <= 0 {
+		nw = numWorkers()
+	}
 
 	fileChan := make(chan string, nw)
 	resultChan := make(chan *result, nw)
 	done := make(chan struct{})
+	fh := newFileHasher(opts)
 
+	// dispatched is the number of filenames sent to fileChan, read once
+	// dispatching is finished.
+	dispatched := 0
+	dispatchDone := make(chan struct{})
 	go func() {
+		defer close(dispatchDone)
 		defer close(fileChan)
 		for _, filename := range filenames {
 			select {
@@ -262,20 +651,31 @@ func Parallel(ctx context.Context, srv Server, cache Cache, filenames []string,
 			case <-ctx.Done():
 				return
 			case fileChan <- filename:
+				dispatched++
 			}
 		}
 	}()
 
 	go func() {
 		for r := range resultChan {
+			if opts.OnHashed != nil && r.stats != nil {
+				opts.OnHashed(r.filename, *r.stats)
+			}
 			onResult(r.filename, r.sums, r.err)
 		}
 		close(done)
 	}()
 
+	algorithms := srv.Algorithms()
 	var wg sync.WaitGroup
-	for range nw {
+	for i := range nw {
 		wg.Go(func() {
+			if opts.Affinity {
+				// The thread is never unlocked, so it exits with the worker
+				// instead of returning to the scheduler with a pinned mask.
+				runtime.LockOSThread()
+				_ = pinThread(i)
+			}
 			for {
 				select {
 				case <-ctx.Done():
@@ -284,22 +684,22 @@ func Parallel(ctx context.Context, srv Server, cache Cache, filenames []string,
 					if !ok {
 						return
 					}
-					if cache != nil {
-						if sums, err := cache.Get(filename); err == nil && len(sums) > 0 {
-							resultChan <- &result{filename, sums, nil}
-							continue
-						}
-					}
-					sums, err := hashFile(filename, srv.NewHash())
-					if cache != nil && err == nil {
-						_ = cache.Set(filename, sums)
-					}
-					resultChan <- &result{filename, sums, err}
+					resultChan <- fh.hashCached(srv, cache, algorithms, filename)
 				}
 			}
 		})
 	}
 	wg.Wait()
+	<-dispatchDone
+	if opts.ReportCanceled && ctx.Err() != nil {
+		// Files still queued were never picked up by a worker.
+		for filename := range fileChan {
+			resultChan <- &result{filename, nil, ctx.Err(), nil}
+		}
+		for _, filename := range filenames[dispatched:] {
+			resultChan <- &result{filename, nil, ctx.Err(), nil}
+		}
+	}
 	close(resultChan)
 	<-done
 }
@@ -1,17 +1,24 @@
 package xsum_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ophymx/utils/xsum"
 )
@@ -69,6 +76,104 @@ func TestNewServerUnknownAlgorithm(t *testing.T) {
 	}
 }
 
+func TestServerAlgorithms(t *testing.T) {
+	srv := newServer(t, "sha256", "md5", "sha256", "sha1")
+	if got, want := srv.Algorithms(), []string{"md5", "sha1", "sha256"}; !slices.Equal(got, want) {
+		t.Errorf("Algorithms() = %v, want %v", got, want)
+	}
+	if got, want := newServer(t, "sha512").Algorithms(), []string{"sha512"}; !slices.Equal(got, want) {
+		t.Errorf("Algorithms() = %v, want %v", got, want)
+	}
+}
+
+func TestBLAKE3KnownVector(t *testing.T) {
+	srv := newServer(t, "blake3", "sha256", "md5")
+	h := srv.NewHash()
+	defer h.Close()
+	if got := h.BlockSize(); got != 64 {
+		t.Errorf("BlockSize() = %d, want 64", got)
+	}
+	// BLAKE3 of the empty input, from the reference test vectors.
+	const want = "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"
+	sums := h.MultiSum()
+	if got := hex(sums["blake3"]); got != want {
+		t.Errorf("blake3: got %s, want %s", got, want)
+	}
+	if len(sums) != 3 {
+		t.Errorf("expected 3 keys in MultiSum, got %v", keys(sums))
+	}
+}
+
+func TestXXHashKnownVectors(t *testing.T) {
+	srv := newServer(t, "xxh64", "xxh3", "sha256")
+	h := srv.NewHash()
+	defer h.Close()
+	// Reference values as printed by xxhsum -H64 and xxhsum -H3.
+	for _, tc := range []struct{ input, xxh64, xxh3 string }{
+		{"", "ef46db3751d8e999", "2d06800538d394c2"},
+		{"abc", "44bc2cf5ad770999", "78af5f94892f3950"},
+	} {
+		h.Reset()
+		h.Write([]byte(tc.input))
+		sums := h.MultiSum()
+		if got := hex(sums["xxh64"]); got != tc.xxh64 {
+			t.Errorf("xxh64(%q): got %s, want %s", tc.input, got, tc.xxh64)
+		}
+		if got := hex(sums["xxh3"]); got != tc.xxh3 {
+			t.Errorf("xxh3(%q): got %s, want %s", tc.input, got, tc.xxh3)
+		}
+		if len(sums) != 3 {
+			t.Errorf("expected 3 keys in MultiSum, got %v", keys(sums))
+		}
+	}
+}
+
+func TestCRCKnownVectors(t *testing.T) {
+	srv := newServer(t, "crc32", "crc64")
+	h := srv.NewHash()
+	defer h.Close()
+	// The standard check values of "123456789" for CRC-32/ISO-HDLC and
+	// CRC-64/GO-ISO.
+	h.Write([]byte("123456789"))
+	sums := h.MultiSum()
+	if got := hex(sums["crc32"]); got != "cbf43926" {
+		t.Errorf("crc32: got %s, want cbf43926", got)
+	}
+	if got := hex(sums["crc64"]); got != "b90956c775a41001" {
+		t.Errorf("crc64: got %s, want b90956c775a41001", got)
+	}
+}
+
+func TestSHA3KnownVectors(t *testing.T) {
+	// FIPS 202 example values for the empty message and "abc".
+	for _, tc := range []struct {
+		algorithm string
+		size      int
+		empty     string
+		abc       string
+	}{
+		{"sha3-256", 32,
+			"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a",
+			"3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+		{"sha3-512", 64,
+			"a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26",
+			"b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0"},
+	} {
+		h := newServer(t, tc.algorithm).NewHash()
+		if got := len(h.MultiSum()[tc.algorithm]); got != tc.size {
+			t.Errorf("%s: digest is %d bytes, want %d", tc.algorithm, got, tc.size)
+		}
+		if got := hex(h.MultiSum()[tc.algorithm]); got != tc.empty {
+			t.Errorf("%s(\"\"): got %s, want %s", tc.algorithm, got, tc.empty)
+		}
+		h.Write([]byte("abc"))
+		if got := hex(h.MultiSum()[tc.algorithm]); got != tc.abc {
+			t.Errorf("%s(\"abc\"): got %s, want %s", tc.algorithm, got, tc.abc)
+		}
+		h.Close()
+	}
+}
+
 // ── single-algorithm hashers ──────────────────────────────────────────────────
 
 var singleAlgoTests = []struct {
@@ -182,6 +287,40 @@ func TestMultiHasherCorrectDigests(t *testing.T) {
 	}
 }
 
+func TestMultiHasherLargeWrite(t *testing.T) {
+	input := []byte(strings.Repeat("0123456789abcdef", 1<<14))
+	srv := newServer(t, "md5", "sha256", "sha512")
+	h := srv.NewHash()
+	defer h.Close()
+
+	if n, err := h.Write(input); err != nil || n != len(input) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	sums := h.MultiSum()
+	for _, tc := range singleAlgoTests {
+		if tc.algo == "sha1" {
+			continue
+		}
+		if want := tc.want(input); string(sums[tc.algo]) != string(want) {
+			t.Errorf("%s: got %s, want %s", tc.algo, hex(sums[tc.algo]), hex(want))
+		}
+	}
+}
+
+func TestMultiHasherStableOrder(t *testing.T) {
+	want := []string{"md5", "sha1", "sha256", "sha512"}
+	for _, algorithms := range [][]string{
+		{"sha512", "sha256", "sha1", "md5"},
+		{"sha1", "md5", "sha512", "sha256"},
+	} {
+		h := newServer(t, algorithms...).NewHash()
+		if got := xsum.HasherOrder(h); !slices.Equal(got, want) {
+			t.Errorf("NewServer(%v) order = %v, want %v", algorithms, got, want)
+		}
+		h.Close()
+	}
+}
+
 func TestMultiHasherBlockSizeLCM(t *testing.T) {
 	// md5 block=64, sha256 block=64 → LCM=64
 	// sha512 block=128 → LCM(64,128)=128
@@ -218,6 +357,151 @@ func TestMultiHasherReset(t *testing.T) {
 	}
 }
 
+func BenchmarkMultiHasherLargeFile(b *testing.B) {
+	srv, err := xsum.NewServer("md5", "sha256", "sha512")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Close()
+	buf := make([]byte, 2<<20)
+	const writes = 32
+
+	b.SetBytes(int64(len(buf)) * writes)
+	for b.Loop() {
+		h := srv.NewHash()
+		for range writes {
+			if _, err := h.Write(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+		h.MultiSum()
+		h.Close()
+	}
+}
+
+// BenchmarkParallelMultiAlgorithm hashes one large file with several
+// digests. With 32K reads the digests are fed serially; with the default
+// buffer each write is fanned out to them concurrently.
+func BenchmarkParallelMultiAlgorithm(b *testing.B) {
+	const size = 64 << 20
+	path := filepath.Join(b.TempDir(), "large")
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		b.Fatal(err)
+	}
+	srv, err := xsum.NewServer("md5", "sha256", "sha512")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Close()
+
+	for _, bufSize := range []int{32 << 10, xsum.DefaultBufferSize} {
+		b.Run(fmt.Sprintf("bufsize=%dK", bufSize>>10), func(b *testing.B) {
+			b.SetBytes(size)
+			for b.Loop() {
+				xsum.ParallelOptions(context.Background(), srv, nil, []string{path}, xsum.Options{BufferSize: bufSize}, func(_ string, _ map[string][]byte, err error) {
+					if err != nil {
+						b.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestCombine(t *testing.T) {
+	input := []byte("hello world")
+	crypto, err := xsum.NewServer("sha256", "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := xsum.NewServer("md5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := xsum.Combine(crypto, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	if got, want := srv.Algorithms(), []string{"md5", "sha256", "sha512"}; !slices.Equal(got, want) {
+		t.Errorf("Algorithms() = %v, want %v", got, want)
+	}
+	h := srv.NewHash()
+	defer h.Close()
+	if _, err := h.Write(input); err != nil {
+		t.Fatal(err)
+	}
+	sums := h.MultiSum()
+	for _, tc := range singleAlgoTests {
+		if tc.algo == "sha1" {
+			continue
+		}
+		if want := tc.want(input); string(sums[tc.algo]) != string(want) {
+			t.Errorf("%s: got %s, want %s", tc.algo, hex(sums[tc.algo]), hex(want))
+		}
+	}
+}
+
+func TestCombineEmpty(t *testing.T) {
+	if srv, err := xsum.Combine(); err == nil {
+		t.Fatalf("expected an error for no servers, got %v", srv)
+	}
+}
+
+func TestHashReader(t *testing.T) {
+	input := "hello world"
+	srv := newServer(t, "md5", "sha256")
+	sums, err := xsum.HashReader(srv, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("HashReader: %v", err)
+	}
+	want := sha256.Sum256([]byte(input))
+	if string(sums["sha256"]) != string(want[:]) {
+		t.Errorf("sha256: got %s, want %s", hex(sums["sha256"]), hex(want[:]))
+	}
+}
+
+func TestHashingReader(t *testing.T) {
+	input := "hello world"
+	hr, err := xsum.NewHashingReader(strings.NewReader(input), "md5", "sha256")
+	if err != nil {
+		t.Fatalf("NewHashingReader: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := hr.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if sums := hr.Sums(); sums != nil {
+		t.Fatalf("Sums before EOF: got %v, want nil", sums)
+	}
+	rest, err := io.ReadAll(hr)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if got := string(buf) + string(rest); got != input {
+		t.Fatalf("read %q, want %q", got, input)
+	}
+	sums := hr.Sums()
+	wantSHA := sha256.Sum256([]byte(input))
+	wantMD5 := md5.Sum([]byte(input))
+	if string(sums["sha256"]) != string(wantSHA[:]) {
+		t.Errorf("sha256: got %s, want %s", hex(sums["sha256"]), hex(wantSHA[:]))
+	}
+	if string(sums["md5"]) != string(wantMD5[:]) {
+		t.Errorf("md5: got %s, want %s", hex(sums["md5"]), hex(wantMD5[:]))
+	}
+	if err := hr.Close(); err != nil {
+		t.Errorf("Close after EOF: %v", err)
+	}
+}
+
+func TestHashingReaderUnknownAlgorithm(t *testing.T) {
+	if _, err := xsum.NewHashingReader(strings.NewReader(""), "crc99"); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+}
+
 // ── Parallel ──────────────────────────────────────────────────────────────────
 
 func TestParallelCorrectSums(t *testing.T) {
@@ -284,6 +568,225 @@ func TestParallelMissingFile(t *testing.T) {
 	}
 }
 
+func TestParallelSnapshot(t *testing.T) {
+	path := writeTempFile(t, "hello")
+	srv := newServer(t, "sha256")
+
+	var gotSums map[string][]byte
+	xsum.ParallelOptions(context.Background(), srv, nil, []string{path}, xsum.Options{Snapshot: true}, func(_ string, sums map[string][]byte, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		gotSums = sums
+	})
+
+	want := sha256.Sum256([]byte("hello"))
+	if string(gotSums["sha256"]) != string(want[:]) {
+		t.Errorf("got %s, want %s", hex(gotSums["sha256"]), hex(want[:]))
+	}
+}
+
+// truncatingServer truncates path on its hashers' first write, as if the
+// file were cut short while it is being read.
+type truncatingServer struct {
+	xsum.Server
+	path string
+}
+
+func (s *truncatingServer) NewHash() xsum.Hasher {
+	return &truncatingHasher{s.Server.NewHash(), s.path, false}
+}
+
+type truncatingHasher struct {
+	xsum.Hasher
+	path      string
+	truncated bool
+}
+
+func (h *truncatingHasher) Write(p []byte) (int, error) {
+	if !h.truncated {
+		h.truncated = true
+		if err := os.Truncate(h.path, 0); err != nil {
+			return 0, err
+		}
+	}
+	return h.Hasher.Write(p)
+}
+
+func TestParallelSnapshotTruncated(t *testing.T) {
+	path := writeTempFile(t, strings.Repeat("x", 100))
+	srv := &truncatingServer{newServer(t, "sha256"), path}
+
+	var gotErr error
+	xsum.ParallelOptions(context.Background(), srv, nil, []string{path}, xsum.Options{Snapshot: true, BufferSize: 10}, func(_ string, _ map[string][]byte, err error) {
+		gotErr = err
+	})
+	if !errors.Is(gotErr, xsum.ErrFileChanged) {
+		t.Errorf("got %v, want ErrFileChanged", gotErr)
+	}
+}
+
+func TestParallelBufferSize(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	path := writeTempFile(t, content)
+	srv := newServer(t, "sha256")
+
+	var gotSums map[string][]byte
+	xsum.ParallelOptions(context.Background(), srv, nil, []string{path}, xsum.Options{BufferSize: 7}, func(_ string, sums map[string][]byte, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		gotSums = sums
+	})
+
+	want := sha256.Sum256([]byte(content))
+	if string(gotSums["sha256"]) != string(want[:]) {
+		t.Errorf("got %s, want %s", hex(gotSums["sha256"]), hex(want[:]))
+	}
+}
+
+func TestParallelLargeFile(t *testing.T) {
+	content := make([]byte, 3<<20)
+	for i := range content {
+		content[i] = byte(i * 7 / 5)
+	}
+	path := writeTempFile(t, string(content))
+	srv := newServer(t, "md5", "sha256")
+
+	// Several reads through one reused buffer, ending on a partial block.
+	var gotSums map[string][]byte
+	xsum.ParallelOptions(context.Background(), srv, nil, []string{path}, xsum.Options{BufferSize: 64<<10 + 3}, func(_ string, sums map[string][]byte, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		gotSums = sums
+	})
+
+	wantMD5, wantSHA256 := md5.Sum(content), sha256.Sum256(content)
+	if !bytes.Equal(gotSums["md5"], wantMD5[:]) {
+		t.Errorf("md5: got %s, want %s", hex(gotSums["md5"]), hex(wantMD5[:]))
+	}
+	if !bytes.Equal(gotSums["sha256"], wantSHA256[:]) {
+		t.Errorf("sha256: got %s, want %s", hex(gotSums["sha256"]), hex(wantSHA256[:]))
+	}
+}
+
+// writeSizeServer records the size of every write its hasher receives.
+type writeSizeServer struct {
+	xsum.Server
+	mu     sync.Mutex
+	writes []int
+}
+
+func (s *writeSizeServer) NewHash() xsum.Hasher {
+	return &writeSizeHasher{s.Server.NewHash(), s}
+}
+
+type writeSizeHasher struct {
+	xsum.Hasher
+	srv *writeSizeServer
+}
+
+func (h *writeSizeHasher) Write(p []byte) (int, error) {
+	h.srv.mu.Lock()
+	h.srv.writes = append(h.srv.writes, len(p))
+	h.srv.mu.Unlock()
+	return h.Hasher.Write(p)
+}
+
+func TestParallelBufferSizeWrites(t *testing.T) {
+	const bufSize = 100 << 10
+	path := writeTempFile(t, strings.Repeat("x", 2*bufSize+bufSize/2))
+	for _, snapshot := range []bool{false, true} {
+		srv := &writeSizeServer{Server: newServer(t, "sha256")}
+		xsum.ParallelOptions(context.Background(), srv, nil, []string{path}, xsum.Options{BufferSize: bufSize, Snapshot: snapshot}, func(_ string, _ map[string][]byte, err error) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		// Regular files fill the buffer on every read but the last.
+		if want := []int{bufSize, bufSize, bufSize / 2}; !slices.Equal(srv.writes, want) {
+			t.Errorf("snapshot=%v: writes %v, want %v", snapshot, srv.writes, want)
+		}
+	}
+}
+
+// busyServer records the most hashers writing at once. Each write holds
+// its file open a little longer, so workers overlap.
+type busyServer struct {
+	xsum.Server
+	mu           sync.Mutex
+	active, peak int
+}
+
+func (s *busyServer) NewHash() xsum.Hasher {
+	return &busyHasher{s.Server.NewHash(), s}
+}
+
+type busyHasher struct {
+	xsum.Hasher
+	srv *busyServer
+}
+
+func (h *busyHasher) Write(p []byte) (int, error) {
+	h.srv.mu.Lock()
+	h.srv.active++
+	h.srv.peak = max(h.srv.peak, h.srv.active)
+	h.srv.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	h.srv.mu.Lock()
+	h.srv.active--
+	h.srv.mu.Unlock()
+	return h.Hasher.Write(p)
+}
+
+func TestParallelMaxOpenFiles(t *testing.T) {
+	var paths []string
+	for i := range 16 {
+		paths = append(paths, writeTempFile(t, fmt.Sprint(i)))
+	}
+	for _, maxOpen := range []int{0, 2, 1} {
+		srv := &busyServer{Server: newServer(t, "sha256")}
+		opts := xsum.Options{Workers: 8, MaxOpenFiles: maxOpen}
+		xsum.ParallelOptions(context.Background(), srv, nil, paths, opts, func(_ string, _ map[string][]byte, err error) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		switch {
+		case maxOpen == 0 && srv.peak <= 2:
+			// Without a bound the eight workers must overlap, or the
+			// bounded runs below prove nothing.
+			t.Errorf("no limit: only %d files read at once", srv.peak)
+		case maxOpen > 0 && srv.peak > maxOpen:
+			t.Errorf("MaxOpenFiles=%d: %d files read at once", maxOpen, srv.peak)
+		}
+	}
+}
+
+func TestParallelOnHashed(t *testing.T) {
+	path := writeTempFile(t, "hello")
+	srv := newServer(t, "sha256")
+	cache := newMemCache()
+
+	var calls []xsum.HashStats
+	opts := xsum.Options{OnHashed: func(filename string, stats xsum.HashStats) {
+		if filename != path {
+			t.Errorf("OnHashed called for %s", filename)
+		}
+		calls = append(calls, stats)
+	}}
+	xsum.ParallelOptions(context.Background(), srv, cache, []string{path}, opts, func(string, map[string][]byte, error) {})
+	xsum.ParallelOptions(context.Background(), srv, cache, []string{path}, opts, func(string, map[string][]byte, error) {})
+
+	if len(calls) != 1 {
+		t.Fatalf("expected OnHashed once (second run is a cache hit), got %d", len(calls))
+	}
+	if calls[0].Bytes != 5 {
+		t.Errorf("Bytes = %d, want 5", calls[0].Bytes)
+	}
+}
+
 // ── Cache ─────────────────────────────────────────────────────────────────────
 
 type memCache struct {
@@ -311,6 +814,112 @@ func (c *memCache) Set(filename string, sums map[string][]byte) error {
 	return nil
 }
 
+func TestParallelPartialCacheHitRecomputes(t *testing.T) {
+	path := writeTempFile(t, "hello")
+	srv := newServer(t, "md5", "sha256")
+	cache := newMemCache()
+	cache.data[path] = map[string][]byte{"sha256": []byte("sentinel-value")}
+
+	var gotSums map[string][]byte
+	xsum.Parallel(context.Background(), srv, cache, []string{path}, func(_ string, sums map[string][]byte, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		gotSums = sums
+	})
+	want := sha256.Sum256([]byte("hello"))
+	if !bytes.Equal(gotSums["sha256"], want[:]) || gotSums["md5"] == nil {
+		t.Fatalf("expected a cache entry without md5 to be recomputed, got %v", gotSums)
+	}
+}
+
+// statCache records what Parallel passes to SetStat.
+type statCache struct {
+	*memCache
+	infos map[string]fs.FileInfo
+}
+
+func (c *statCache) Set(string, map[string][]byte) error {
+	panic("Set called on a StatCache")
+}
+
+func (c *statCache) SetStat(filename string, info fs.FileInfo, sums map[string][]byte) error {
+	c.infos[filename] = info
+	return c.memCache.Set(filename, sums)
+}
+
+func TestParallelStatCacheGetsPreReadInfo(t *testing.T) {
+	path := writeTempFile(t, "hello")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(t, "sha256")
+	cache := &statCache{newMemCache(), make(map[string]fs.FileInfo)}
+
+	xsum.Parallel(context.Background(), srv, cache, []string{path}, func(string, map[string][]byte, error) {})
+	info := cache.infos[path]
+	if info == nil || !info.ModTime().Equal(past) || info.Size() != 5 {
+		t.Fatalf("expected SetStat with the file's info, got %v", info)
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	path := writeTempFile(t, "hello")
+	srv := newServer(t, "md5", "sha256")
+	cache := newMemCache()
+
+	sums, err := xsum.HashFile(srv, cache, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256([]byte("hello"))
+	if !bytes.Equal(sums["sha256"], want[:]) || sums["md5"] == nil {
+		t.Fatalf("unexpected sums %v", sums)
+	}
+	if _, ok := cache.data[path]; !ok {
+		t.Error("cache not populated")
+	}
+	cache.data[path] = map[string][]byte{"md5": []byte("m"), "sha256": []byte("s")}
+	if sums, err = xsum.HashFile(srv, cache, path); err != nil || string(sums["sha256"]) != "s" {
+		t.Errorf("expected the cached sums, got %v, %v", sums, err)
+	}
+
+	if _, err = xsum.HashFile(srv, nil, "/nonexistent/file"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	path := writeTempFile(t, "hello")
+	srv := newServer(t, "sha256")
+	cache := xsum.NewMemoryCache()
+
+	if sums, err := cache.Get(path); err != nil || sums != nil {
+		t.Fatalf("expected a miss before Set, got %v, %v", sums, err)
+	}
+	sentinel := map[string][]byte{"sha256": []byte("sentinel-value")}
+	if err := cache.Set(path, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	var gotSums map[string][]byte
+	xsum.Parallel(context.Background(), srv, cache, []string{path}, func(_ string, sums map[string][]byte, err error) {
+		gotSums = sums
+	})
+	if string(gotSums["sha256"]) != "sentinel-value" {
+		t.Fatalf("expected the cached sums, got %x", gotSums["sha256"])
+	}
+
+	// A new modification time makes the entry stale.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if sums, err := cache.Get(path); err != nil || sums != nil {
+		t.Fatalf("expected a miss after the file changed, got %v, %v", sums, err)
+	}
+}
+
 func TestParallelCacheMissPopulatesCache(t *testing.T) {
 	path := writeTempFile(t, "hello")
 	srv := newServer(t, "sha256")
@@ -377,6 +986,74 @@ func TestParallelContextCancellation(t *testing.T) {
 	}
 }
 
+func TestParallelContextReportsUnprocessed(t *testing.T) {
+	n := 200
+	files := make([]string, n)
+	for i := range n {
+		files[i] = writeTempFile(t, strings.Repeat("x", 1<<12))
+	}
+	srv := newServer(t, "sha256")
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	seen := make(map[string]int)
+	canceled := 0
+	xsum.ParallelContext(ctx, srv, nil, files, func(filename string, _ map[string][]byte, err error) {
+		seen[filename]++
+		if errors.Is(err, context.Canceled) {
+			canceled++
+		} else if err != nil {
+			t.Errorf("%s: %v", filename, err)
+		}
+		if len(seen) == 10 {
+			cancel()
+		}
+	})
+	cancel()
+
+	for _, filename := range files {
+		if seen[filename] != 1 {
+			t.Errorf("%s: got %d results, want 1", filename, seen[filename])
+		}
+	}
+	if canceled == 0 {
+		t.Error("expected some files to be reported as canceled")
+	}
+	// Workers and the dispatcher have exited by the time ParallelContext returns.
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines: %d before, %d after", before, after)
+	}
+}
+
+func BenchmarkParallelAffinity(b *testing.B) {
+	dir := b.TempDir()
+	files := make([]string, 32)
+	for i := range files {
+		files[i] = filepath.Join(dir, strings.Repeat("f", i+1))
+		if err := os.WriteFile(files[i], make([]byte, 4<<20), 0600); err != nil {
+			b.Fatal(err)
+		}
+	}
+	srv, err := xsum.NewServer("sha256")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Close()
+
+	for _, affinity := range []bool{false, true} {
+		b.Run(fmt.Sprintf("affinity=%t", affinity), func(b *testing.B) {
+			b.SetBytes(int64(len(files)) << 22)
+			for b.Loop() {
+				xsum.ParallelOptions(context.Background(), srv, nil, files, xsum.Options{Affinity: affinity}, func(_ string, _ map[string][]byte, err error) {
+					if err != nil {
+						b.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
 // ── helpers ───────────────────────────────────────────────────────────────────
 
 func keys(m map[string][]byte) []string {